@@ -164,6 +164,25 @@ func (a *Account) Balance() *Amount {
 	return a.balance
 }
 
+// BalanceAt returns the account balance including all postings dated before time t,
+// using the effective dates of the postings if effective is set.
+func (a *Account) BalanceAt(t time.Time, effective bool) *Amount {
+	balance := NewZeroAmount(a.Commodity)
+	for _, s := range a.Postings {
+		if !s.Date(effective).Before(t) {
+			if !effective {
+				// postings are ordered by posted dates
+				break
+			}
+			continue
+		}
+		err := balance.AddIn(s.Quantity)
+		check.NoError(err, "couldn't add %a %s to balance %a %s: %s\n",
+			s.Quantity, s.Quantity.Commodity, balance, balance.Commodity, s.Transaction.Location())
+	}
+	return balance
+}
+
 func (a *Account) IsClosed() bool {
 	if a == nil {
 		return false
@@ -182,7 +201,13 @@ func (a *Account) CheckPostings() {
 	if len(a.Postings) == 0 {
 		return
 	}
-	for _, s := range a.Postings {
+	// check the balances in the order of effective dates,
+	// so that the running balances match the statements the balance assertions come from
+	ps := append([]*Posting{}, a.Postings...)
+	sort.SliceStable(ps, func(i, j int) bool {
+		return ps[i].Time(true).Before(ps[j].Time(true))
+	})
+	for _, s := range ps {
 		err := a.Balance().AddIn(s.Quantity)
 		check.NoError(err, "couldn't add %a %s to balance %a %s: %s\n",
 			s.Quantity, s.Quantity.Commodity, a.Balance(), a.Balance().Commodity, s.Transaction.Location())
@@ -190,7 +215,7 @@ func (a *Account) CheckPostings() {
 			warn.If(!a.Balance().IsEqual(s.Balance),
 				"%s: %s balance is %a, should be %a: %s\n",
 				a.FullName,
-				s.Transaction.Posted.Format(DateFormat),
+				s.EffectiveDate().Format(DateFormat),
 				a.Balance(),
 				s.Balance,
 				s.Transaction.Location(),
@@ -237,7 +262,7 @@ func (a *Account) adopt(c *Account) {
 
 func (a *Account) sortPostings() {
 	sort.SliceStable(a.Postings, func(i, j int) bool {
-		return a.Postings[i].Transaction.Posted.Before(a.Postings[j].Transaction.Posted)
+		return a.Postings[i].Transaction.PostedAt().Before(a.Postings[j].Transaction.PostedAt())
 	})
 }
 
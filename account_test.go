@@ -99,3 +99,20 @@ func newPosting(date string, a *Account) *Posting {
 		Transaction: &Transaction{Posted: d},
 	}
 }
+
+func Test_BalanceAt(t *testing.T) {
+	a := accountFromName("B")
+	a.Commodity = cad
+	for _, d := range []string{"2000/01", "2000/03", "2000/07"} {
+		a.addPosting(newPosting(d, a))
+	}
+	assert.Equal(t, a.BalanceAt(MustParseDate("2000/01"), false).String(), "0.00")
+	assert.Equal(t, a.BalanceAt(MustParseDate("2000/01/02"), false).String(), "0.01")
+	assert.Equal(t, a.BalanceAt(MustParseDate("2000/07"), false).String(), "0.04")
+	assert.Equal(t, a.BalanceAt(MustParseDate("2001"), false).String(), "0.11")
+	// the March posting takes effect in August
+	a.Postings[1].Effective = MustParseDate("2000/08")
+	assert.Equal(t, a.BalanceAt(MustParseDate("2000/07"), false).String(), "0.04")
+	assert.Equal(t, a.BalanceAt(MustParseDate("2000/07"), true).String(), "0.01")
+	assert.Equal(t, a.BalanceAt(MustParseDate("2000/09"), true).String(), "0.11")
+}
@@ -5,26 +5,23 @@ import (
 	"fmt"
 	"io"
 	"math/big"
-	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/mkobetic/coin/rex"
 )
 
-var AmountREX = rex.MustCompile(`(?P<amount>-?[\d]+(?P<decimals>\.[\d]+)?)\s+%s`, CommodityREX)
+var AmountREX = rex.MustCompile(`(?P<amount>-?(?:\d{1,3}(?:,\d{3})+|\d+)(?P<decimals>\.[\d]+)?)\s+%s`, CommodityREX)
 
 type Amount struct {
 	*big.Int
 	*Commodity
 }
 
+// NewAmountFrac returns num/den as an amount of commodity c
+// rounded using the rounding mode of the commodity.
 func NewAmountFrac(num, den *big.Int, c *Commodity) *Amount {
-	b := new(big.Int).Set(num)
-	if c.Decimals > 0 {
-		b.Mul(b, bigPow10(c.Decimals))
-	}
-	b.Div(b, den)
-	return NewAmount(b, c)
+	return c.NewAmountRat(new(big.Rat).SetFrac(num, den))
 }
 
 func NewZeroAmount(c *Commodity) *Amount {
@@ -45,7 +42,8 @@ func (a *Amount) String() string {
 	return val.FloatString(a.Commodity.Decimals)
 }
 
-// Format implements fmt.Formatter
+// Format implements fmt.Formatter, the f verb uses the ledger syntax,
+// other verbs (e.g. a) use the Output locale.
 func (a *Amount) Format(f fmt.State, c rune) {
 	if f.Flag('#') {
 		fmt.Fprintf(f, "%d/(10^%d) %s", a.Int, a.Decimals, a.Commodity.Id)
@@ -55,9 +53,11 @@ func (a *Amount) Format(f fmt.State, c rune) {
 	if !ok {
 		p = a.Commodity.Decimals
 	}
-	val := new(big.Rat)
-	val.SetFrac(a.Int, bigPow10(a.Commodity.Decimals))
-	str := val.FloatString(p)
+	l := Output
+	if c == 'f' {
+		l = ledgerLocale
+	}
+	str := a.format(p, l)
 	if f.Flag(' ') && a.Sign() >= 0 {
 		str = " " + str
 	}
@@ -72,36 +72,32 @@ func (a *Amount) Format(f fmt.State, c rune) {
 	}
 }
 
+// format presents the amount with the given number of decimals in the locale
+func (a *Amount) format(decimals int, l *Locale) string {
+	return l.number(a.Rat().FloatString(decimals), l.separator(a.Commodity))
+}
+
+// parseAmount parses decimal values of arbitrary size,
+// optionally with thousands separators (e.g. 1,234,567.89).
 func parseAmount(s string, c *Commodity) (*Amount, error) {
-	ss := strings.Split(s, ".")
-	if len(ss) == 1 {
-		i, err := strconv.ParseInt(ss[0], 10, 64)
-		if err != nil {
-			return nil, err
-		}
-		bi := big.NewInt(i)
-		if c.Decimals > 0 {
-			bi.Mul(bi, bigPow10(c.Decimals))
-		}
-		return NewAmount(bi, c), nil
-	}
-	if len(ss) != 2 {
+	whole, frac, _ := strings.Cut(strings.ReplaceAll(s, ",", ""), ".")
+	bi, ok := new(big.Int).SetString(whole+frac, 10)
+	if !ok {
 		return nil, fmt.Errorf("malformed value %s", s)
 	}
-	s = strings.Join(ss, "")
-	i, err := strconv.ParseInt(s, 10, 64)
-	if err != nil {
-		return nil, err
-	}
-	bi := big.NewInt(i)
-	decimals := len(ss[1])
+	decimals := len(frac)
 	if c.Decimals != decimals {
 		bi.Mul(bi, bigPow10(c.Decimals))
-		bi.Div(bi, bigPow10(decimals))
+		bi.Quo(bi, bigPow10(decimals))
 	}
 	return NewAmount(bi, c), nil
 }
 
+// Rat returns the exact value of the amount
+func (a *Amount) Rat() *big.Rat {
+	return new(big.Rat).SetFrac(a.Int, bigPow10(a.Decimals))
+}
+
 func (a *Amount) Write(w io.Writer, ledger bool) error {
 	_, err := fmt.Fprintf(w, "%.*f %s", a.Decimals, a, a.SafeId(ledger))
 	return err
@@ -180,21 +176,44 @@ func MustParseAmount(f string, c *Commodity) *Amount {
 	return amt
 }
 
+// Width returns the width of the amount with the given number of decimals
+// as presented by the Output locale.
 func (a *Amount) Width(decimals int) int {
-	w := bigLog10(a.Int) + 1
-	if w <= a.Decimals {
-		w = a.Decimals + 1
-	}
-	w = w - a.Decimals + decimals
-	if decimals > 0 {
-		w++ // decimal point
-	}
-	if a.Int.Sign() < 0 {
-		w++ // minus sign
-	}
-	return w
+	return a.width(decimals, Output)
+}
+
+func (a *Amount) width(decimals int, l *Locale) int {
+	return utf8.RuneCountInString(a.format(decimals, l))
 }
 
 func (a *Amount) MarshalJSON() ([]byte, error) {
-	return json.Marshal(fmt.Sprintf("%.*f %s", a.Commodity.Decimals, a, a.Commodity.Id))
+	return json.Marshal(a.String() + " " + a.Commodity.Id)
+}
+
+// Rounding modes applied when an exact value is turned into an amount of a commodity.
+const (
+	Truncate = "truncate"  // toward zero (default)
+	HalfEven = "half-even" // to nearest, ties to even
+	HalfUp   = "half-up"   // to nearest, ties away from zero
+)
+
+// round returns r scaled by 10^decimals and rounded to an integer using the rounding mode.
+func round(r *big.Rat, decimals int, mode string) *big.Int {
+	num := new(big.Int).Mul(r.Num(), bigPow10(decimals))
+	q, m := new(big.Int).QuoRem(num, r.Denom(), new(big.Int))
+	if m.Sign() == 0 || mode == "" || mode == Truncate {
+		return q
+	}
+	// compare twice the remainder with the denominator
+	half := new(big.Int).Abs(m)
+	half.Lsh(half, 1)
+	cmp := half.Cmp(r.Denom())
+	if cmp > 0 || cmp == 0 && (mode == HalfUp || q.Bit(0) == 1) {
+		if num.Sign() < 0 {
+			q.Sub(q, big1)
+		} else {
+			q.Add(q, big1)
+		}
+	}
+	return q
 }
@@ -25,6 +25,8 @@ func Test_ParseAmount(t *testing.T) {
 		{"-50.01", "-50.01"},
 		{"0.011", "0.01"},
 		{"-100.00", "-100.00"},
+		{"1,234,567.89", "1234567.89"},
+		{"-98765432109876543210.123", "-98765432109876543210.12"},
 	} {
 		amt, err := parseAmount(fix.in, cad)
 		assert.NoError(t, err)
@@ -48,6 +50,30 @@ func Test_AmountWidth(t *testing.T) {
 	}
 }
 
+func Test_AmountThousands(t *testing.T) {
+	jpy := &Commodity{Id: "JPY", Thousands: true}
+	for i, fix := range []struct {
+		amt   string
+		width int
+		out   string
+	}{
+		{"0", 1, "0"},
+		{"999", 3, "999"},
+		{"-1000", 6, "-1,000"},
+		{"1234567", 9, "1,234,567"},
+	} {
+		amt, err := parseAmount(fix.amt, jpy)
+		assert.NoError(t, err)
+		assert.Equal(t, amt.Width(0), fix.width, "%d. width not equal", i)
+		assert.Equal(t, fmt.Sprintf("%a", amt), fix.out, "%d. not equal", i)
+		assert.Equal(t, amt.String(), fix.amt, "%d. string not equal", i)
+	}
+	eur := &Commodity{Id: "EUR", Decimals: 2, Thousands: true}
+	amt := MustParseAmount("-12345.6", eur)
+	assert.Equal(t, amt.Width(2), 10)
+	assert.Equal(t, fmt.Sprintf("%12.2f", amt), "  -12,345.60")
+}
+
 func Test_AmountIsEqual(t *testing.T) {
 	amt1, err := parseAmount("12.33", cad)
 	assert.NoError(t, err)
@@ -128,6 +154,28 @@ func Test_NewAmountFracDec(t *testing.T) {
 	}
 }
 
+func Test_NewAmountRatRounding(t *testing.T) {
+	for i, fix := range []struct {
+		value, mode, out string
+	}{
+		{"1.005", Truncate, "1.00"},
+		{"-1.005", Truncate, "-1.00"},
+		{"1.005", HalfEven, "1.00"},
+		{"1.015", HalfEven, "1.02"},
+		{"-1.015", HalfEven, "-1.02"},
+		{"1.0051", HalfEven, "1.01"},
+		{"1.005", HalfUp, "1.01"},
+		{"-1.005", HalfUp, "-1.01"},
+		{"1.0049", HalfUp, "1.00"},
+		{"2/3", "", "0.66"},
+		{"2/3", HalfUp, "0.67"},
+	} {
+		r, _ := new(big.Rat).SetString(fix.value)
+		c := &Commodity{Id: "CAD", Decimals: 2, Rounding: fix.mode}
+		assert.Equal(t, c.NewAmountRat(r).String(), fix.out, "%d. not equal", i)
+	}
+}
+
 func mustParseBigInt(s string) *big.Int {
 	i := new(big.Int)
 	i.SetString(s, 10)
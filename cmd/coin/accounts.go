@@ -15,6 +15,7 @@ func init() {
 type cmdAccounts struct {
 	flagsWithUsage
 	closed bool
+	output string
 }
 
 func (*cmdAccounts) newCommand(names ...string) command {
@@ -24,6 +25,7 @@ func (*cmdAccounts) newCommand(names ...string) command {
 
 Lists accounts and their commodities.`)
 	cmd.BoolVar(&cmd.closed, "c", false, "show closed accounts")
+	outputFlag(cmd.FlagSet, &cmd.output)
 	return &cmd
 }
 
@@ -38,6 +40,7 @@ func (cmd *cmdAccounts) execute(f io.Writer) {
 	if cmd.NArg() > 0 {
 		pattern = coin.ToRegex(cmd.Arg(0))
 	}
+	var accounts []*coin.Account
 	var max int
 	coin.AccountsDo(func(a *coin.Account) {
 		if pattern != nil && !pattern.MatchString(a.FullName) {
@@ -46,14 +49,20 @@ func (cmd *cmdAccounts) execute(f io.Writer) {
 		if l := len(a.FullName); l > max {
 			max = l
 		}
-	})
-	coin.AccountsDo(func(a *coin.Account) {
 		if !cmd.closed && a.IsClosed() {
 			return
 		}
-		if pattern != nil && !pattern.MatchString(a.FullName) {
-			return
+		accounts = append(accounts, a)
+	})
+	if cmd.output != outText {
+		rs := rows{{"Account", "Commodity", "Description"}}
+		for _, a := range accounts {
+			rs = append(rs, []string{a.FullName, a.CommodityId, a.Description})
 		}
+		rs.write(f, cmd.output)
+		return
+	}
+	for _, a := range accounts {
 		fmt.Fprintf(f, "%-*s | %-10s | %s\n", max, a.FullName, a.CommodityId, a.Description)
-	})
+	}
 }
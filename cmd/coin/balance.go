@@ -16,10 +16,12 @@ func init() {
 type cmdBalance struct {
 	flagsWithUsage
 	begin, end  coin.Date
+	effective   bool
 	payee       string
 	tag         string
 	zeroBalance bool
 	level       int
+	output      string
 }
 
 func (*cmdBalance) newCommand(names ...string) command {
@@ -30,10 +32,12 @@ func (*cmdBalance) newCommand(names ...string) command {
 Lists balances for account and its subaccounts (default: Root).`)
 	cmd.Var(&cmd.begin, "b", "begin balance from this date")
 	cmd.Var(&cmd.end, "e", "end balance on this date")
+	cmd.BoolVar(&cmd.effective, "effective", false, "use effective dates of postings (DATE=DATE2, [=DATE])")
 	cmd.StringVar(&cmd.payee, "p", "", "use only postings matching the payee (regex)")
 	cmd.StringVar(&cmd.tag, "t", "", "use only postings matching the tag[:value] (regex)")
 	cmd.BoolVar(&cmd.zeroBalance, "z", false, "list accounts with zero total balance")
 	cmd.IntVar(&cmd.level, "l", 0, "print accounts up to this level, 0 means all")
+	outputFlag(cmd.FlagSet, &cmd.output, outChart)
 	return &cmd
 }
 
@@ -46,44 +50,58 @@ func (cmd *cmdBalance) execute(f io.Writer) {
 	if cmd.NArg() > 0 {
 		account = coin.MustFindAccount(cmd.Arg(0))
 	}
-	totals := make(balances)
-	cumulative := make(balances)
-	account.WithChildrenDo(func(a *coin.Account) {
-		total := coin.NewZeroAmount(a.Commodity)
-		for _, p := range cmd.trim(a.Postings) {
-			err := total.AddIn(p.Quantity)
-			check.NoError(err, "adding posting for %s: %s\n", a.FullName, p.Transaction.Location())
-		}
-		totals[a] = total
-		cumulative[a] = total.Copy()
-	})
-	account.FirstWithChildrenDo(func(a *coin.Account) {
-		cump := cumulative[a.Parent]
-		if cump == nil {
-			return
-		}
-		cum := cumulative[a]
-		err := cump.AddIn(cum)
-		check.NoError(err, "cannot add total to parent of %s\n", a.FullName)
-	})
-	cmd.print(f, account, totals, cumulative)
+	totals, cumulative := accountBalances(account, cmd.trim)
+	switch cmd.output {
+	case outText:
+		cmd.print(f, account, totals, cumulative)
+	case outChart:
+		cmd.chart(account, cumulative).print(f, chartBar)
+	default:
+		cmd.rows(account, totals, cumulative).write(f, cmd.output)
+	}
 }
 
-func (cmd *cmdBalance) print(f io.Writer, acc *coin.Account, totals, cumulative balances) {
-	width, cumWidth, curWidth := totals.maxWidth(), cumulative.maxWidth(), cumulative.curWidth()
+// accounts returns the accounts to list
+func (cmd *cmdBalance) accounts(acc *coin.Account, cumulative balances) (accounts []*coin.Account) {
 	acc.WithChildrenDo(func(a *coin.Account) {
 		if cmd.level != 0 && a.Depth() > cmd.level {
 			return
 		}
-		if tot, cum := totals[a], cumulative[a]; cmd.zeroBalance || !cum.IsZero() {
-			fmt.Fprintf(f, "%*a | %*a %-*s | %s\n",
-				width, tot, cumWidth, cum, curWidth, a.CommodityId, a.FullName)
+		if cmd.zeroBalance || !cumulative[a].IsZero() {
+			accounts = append(accounts, a)
 		}
 	})
+	return accounts
+}
+
+func (cmd *cmdBalance) print(f io.Writer, acc *coin.Account, totals, cumulative balances) {
+	width, cumWidth, curWidth := totals.maxWidth(), cumulative.maxWidth(), cumulative.curWidth()
+	for _, a := range cmd.accounts(acc, cumulative) {
+		fmt.Fprintf(f, "%*a | %*a %-*s | %s\n",
+			width, totals[a], cumWidth, cumulative[a], curWidth, a.CommodityId, a.FullName)
+	}
+}
+
+func (cmd *cmdBalance) rows(acc *coin.Account, totals, cumulative balances) (rs rows) {
+	rs = append(rs, []string{"Account", "Total", "Balance", "Commodity"})
+	for _, a := range cmd.accounts(acc, cumulative) {
+		rs = append(rs, []string{a.FullName, totals[a].String(), cumulative[a].String(), a.CommodityId})
+	}
+	return rs
+}
+
+// chart returns the balances as a chart with a bar for each account
+func (cmd *cmdBalance) chart(acc *coin.Account, cumulative balances) *chart {
+	c := &chart{series: []string{"Balance"}}
+	for _, a := range cmd.accounts(acc, cumulative) {
+		c.rows = append(c.rows, a.FullName)
+		c.amounts = append(c.amounts, []*coin.Amount{cumulative[a]})
+	}
+	return c
 }
 
 func (cmd *cmdBalance) trim(ps []*coin.Posting) postings {
-	ps = trim(ps, cmd.begin, cmd.end)
+	ps = trim(ps, cmd.begin, cmd.end, cmd.effective)
 	if len(cmd.payee) > 0 {
 		var pps []*coin.Posting
 		r := regexp.MustCompile("(?i)" + cmd.payee)
@@ -109,6 +127,33 @@ func (cmd *cmdBalance) trim(ps []*coin.Posting) postings {
 
 type balances map[*coin.Account]*coin.Amount
 
+// accountBalances computes the totals of postings selected by trim
+// for the account and each of its subaccounts. The cumulative balances
+// also include the totals of all the subaccounts.
+func accountBalances(account *coin.Account, trim func([]*coin.Posting) postings) (totals, cumulative balances) {
+	totals = make(balances)
+	cumulative = make(balances)
+	account.WithChildrenDo(func(a *coin.Account) {
+		total := coin.NewZeroAmount(a.Commodity)
+		for _, p := range trim(a.Postings) {
+			err := total.AddIn(p.Quantity)
+			check.NoError(err, "adding posting for %s: %s\n", a.FullName, p.Transaction.Location())
+		}
+		totals[a] = total
+		cumulative[a] = total.Copy()
+	})
+	account.FirstWithChildrenDo(func(a *coin.Account) {
+		cump := cumulative[a.Parent]
+		if cump == nil {
+			return
+		}
+		cum := cumulative[a]
+		err := cump.AddIn(cum)
+		check.NoError(err, "cannot add total to parent of %s\n", a.FullName)
+	})
+	return totals, cumulative
+}
+
 func (bs balances) maxWidth() int {
 	var max int
 	for acc, amt := range bs {
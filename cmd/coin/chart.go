@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mkobetic/coin"
+	"github.com/mkobetic/coin/check"
+)
+
+// Terminal chart output format and kinds
+const (
+	outChart = "chart"
+
+	chartBar     = "bar"     // a bar per row and series
+	chartStacked = "stacked" // a bar per row with series stacked on top of each other
+	chartSpark   = "spark"   // a sparkline per series
+)
+
+// chartWidth is the maximum width of the bars
+const chartWidth = 60
+
+// chartFills are the characters used to draw the series
+var chartFills = []rune{'█', '▓', '▒', '░', '#', '=', '+', '*', 'o', '~'}
+
+// sparkLevels are the characters used to draw sparklines
+var sparkLevels = []rune("▁▂▃▄▅▆▇█")
+
+// chart is a table of amounts to be rendered in the terminal,
+// with a row for each period (or account) and a column for each series.
+// Series are labeled the same way as the columns of the text table.
+// Trailing summary series (totals, averages) are not stacked.
+type chart struct {
+	title   string
+	rows    []string
+	series  []string
+	amounts [][]*coin.Amount // [row][series]
+	summary int              // number of trailing summary series
+}
+
+func (c *chart) value(row, series int) float64 {
+	return float(c.amounts[row][series])
+}
+
+// float returns the amount as a float
+func float(a *coin.Amount) float64 {
+	v, _ := new(big.Float).SetInt(a.Int).Float64()
+	return v / math.Pow10(a.Commodity.Decimals)
+}
+
+func (c *chart) fill(series int) rune {
+	return chartFills[series%len(chartFills)]
+}
+
+func (c *chart) print(f io.Writer, kind string) {
+	if len(c.rows) == 0 {
+		return
+	}
+	switch kind {
+	case chartBar:
+		c.printBars(f)
+	case chartStacked:
+		c.printStacked(f)
+	case chartSpark:
+		c.printSparklines(f)
+	default:
+		check.If(false, "unknown chart kind %s", kind)
+	}
+}
+
+// scale returns the number of columns to the left of the axis
+// and the number of columns per unit, given the most negative
+// and the most positive value to draw.
+func scale(min, max float64) (left int, unit float64) {
+	min, max = math.Min(min, 0), math.Max(max, 0)
+	if max-min == 0 {
+		return 0, 0
+	}
+	unit = chartWidth / (max - min)
+	return int(math.Round(-min * unit)), unit
+}
+
+// bar draws the bar from value from to value to (from <= to)
+// around the axis at left.
+func bar(b []rune, left int, unit float64, from, to float64, fill rune) {
+	start := max(0, left+int(math.Round(from*unit)))
+	end := min(chartWidth, left+int(math.Round(to*unit)))
+	for i := start; i < end; i++ {
+		if i < left {
+			b[i] = fill
+		} else {
+			b[i+1] = fill // skip the axis
+		}
+	}
+}
+
+func newBar(left int) []rune {
+	b := []rune(strings.Repeat(" ", chartWidth+1))
+	b[left] = '│'
+	return b
+}
+
+func (c *chart) rowWidth() (width int) {
+	for _, r := range c.rows {
+		width = max(width, utf8.RuneCountInString(r))
+	}
+	return width
+}
+
+func (c *chart) seriesWidth() (width int) {
+	for _, s := range c.series {
+		width = max(width, utf8.RuneCountInString(s))
+	}
+	return width
+}
+
+func (c *chart) printLegend(f io.Writer, series []string) {
+	var items []string
+	for i, s := range series {
+		items = append(items, fmt.Sprintf("%c %s", c.fill(i), s))
+	}
+	fmt.Fprintln(f, strings.Join(items, "  "))
+}
+
+func (c *chart) printBars(f io.Writer) {
+	minV, maxV := 0.0, 0.0
+	for i := range c.rows {
+		for j := range c.series {
+			v := c.value(i, j)
+			minV, maxV = math.Min(minV, v), math.Max(maxV, v)
+		}
+	}
+	left, unit := scale(minV, maxV)
+	rw, sw := c.rowWidth(), c.seriesWidth()
+	if len(c.series) > 1 {
+		c.printLegend(f, c.series)
+	}
+	for i, r := range c.rows {
+		for j, s := range c.series {
+			b := newBar(left)
+			v := c.value(i, j)
+			bar(b, left, unit, math.Min(v, 0), math.Max(v, 0), c.fill(j))
+			if len(c.series) == 1 {
+				fmt.Fprintf(f, "%-*s %s %a\n", rw, r, strings.TrimRight(string(b), " "), c.amounts[i][j])
+				continue
+			}
+			fmt.Fprintf(f, "%-*s %-*s %s %a\n", rw, r, sw, s, strings.TrimRight(string(b), " "), c.amounts[i][j])
+			r = ""
+		}
+	}
+}
+
+func (c *chart) printStacked(f io.Writer) {
+	stacked := c.series[:c.stacked()]
+	minV, maxV := 0.0, 0.0
+	for i := range c.rows {
+		var neg, pos float64
+		for j := range stacked {
+			if v := c.value(i, j); v < 0 {
+				neg += v
+			} else {
+				pos += v
+			}
+		}
+		minV, maxV = math.Min(minV, neg), math.Max(maxV, pos)
+	}
+	left, unit := scale(minV, maxV)
+	rw := c.rowWidth()
+	c.printLegend(f, stacked)
+	for i, r := range c.rows {
+		b := newBar(left)
+		var neg, pos float64
+		total := coin.NewZeroAmount(c.amounts[i][0].Commodity)
+		for j := range stacked {
+			v := c.value(i, j)
+			if v < 0 {
+				bar(b, left, unit, neg+v, neg, c.fill(j))
+				neg += v
+			} else {
+				bar(b, left, unit, pos, pos+v, c.fill(j))
+				pos += v
+			}
+			err := total.AddIn(c.amounts[i][j])
+			check.NoError(err, "adding up %s", r)
+		}
+		fmt.Fprintf(f, "%-*s %s %a\n", rw, r, strings.TrimRight(string(b), " "), total)
+	}
+}
+
+func (c *chart) printSparklines(f io.Writer) {
+	sw := c.seriesWidth()
+	for j, s := range c.series {
+		minV, maxV := c.value(0, j), c.value(0, j)
+		minA, maxA := c.amounts[0][j], c.amounts[0][j]
+		for i := range c.rows {
+			if v := c.value(i, j); v < minV {
+				minV, minA = v, c.amounts[i][j]
+			} else if v > maxV {
+				maxV, maxA = v, c.amounts[i][j]
+			}
+		}
+		var b strings.Builder
+		for i := range c.rows {
+			level := len(sparkLevels) / 2
+			if maxV > minV {
+				level = int(math.Round((c.value(i, j) - minV) / (maxV - minV) * float64(len(sparkLevels)-1)))
+			}
+			b.WriteRune(sparkLevels[level])
+		}
+		fmt.Fprintf(f, "%-*s %s %a .. %a\n", sw, s, b.String(), minA, maxA)
+	}
+	fmt.Fprintf(f, "%-*s %s .. %s\n", sw, "", c.rows[0], c.rows[len(c.rows)-1])
+}
@@ -4,13 +4,12 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
-	"time"
 
 	"github.com/mkobetic/coin"
-	finance "github.com/piquette/finance-go"
-	"github.com/piquette/finance-go/forex"
-	"github.com/piquette/finance-go/quote"
+	"github.com/mkobetic/coin/check"
 )
 
 var ()
@@ -22,8 +21,13 @@ func init() {
 type cmdCommodities struct {
 	flagsWithUsage
 	getQuotes bool
+	from      coin.Date
+	monthly   bool
+	write     bool
 	prices    bool
-	location bool
+	location  bool
+	backfill  bool
+	output    string
 }
 
 func (*cmdCommodities) newCommand(names ...string) command {
@@ -31,69 +35,69 @@ func (*cmdCommodities) newCommand(names ...string) command {
 	cmd.FlagSet = newCommand(&cmd, names...)
 	setUsage(cmd.FlagSet, `(commodities|com|c) [flags] [commodity]
 
-Lists commodities and prices.`)
+Lists commodities and prices.
+Quotes are fetched using the provider specified by the commodity quote directive
+(yahoo, json or file), commodities without the directive use yahoo.
+Quotes that are already in the price database are omitted.`)
 	cmd.BoolVar(&cmd.getQuotes, "q", false, "get current quotes for all commodities")
+	cmd.Var(&cmd.from, "from", "get historical daily quotes from this date (with -q)")
+	cmd.BoolVar(&cmd.monthly, "m", false, "keep only the last historical quote of each month (with -from)")
+	cmd.BoolVar(&cmd.write, "w", false, "append the quotes to the price files (with -q)")
 	cmd.BoolVar(&cmd.prices, "p", false, "print commodity price stats")
 	cmd.BoolVar(&cmd.location, "f", false, "include file location on price list")
+	cmd.BoolVar(&cmd.backfill, "backfill", false, "print prices implied by transactions as price entries")
+	outputFlag(cmd.FlagSet, &cmd.output)
 	return &cmd
 }
 
 func (cmd *cmdCommodities) init() {
-	if cmd.prices || cmd.NArg() > 0 {
+	if cmd.backfill {
+		coin.LoadAll()
+	} else if cmd.getQuotes {
+		// events are not applied, the new quotes are deduped against the prices as written
 		coin.LoadPrices()
 		coin.ResolvePrices()
+	} else if cmd.prices || cmd.NArg() > 0 {
+		coin.LoadPrices()
+		coin.ResolveEvents()
+		coin.ResolvePrices()
 	} else {
 		coin.LoadFile(coin.CommoditiesFile)
 	}
 }
 
 func (cmd *cmdCommodities) execute(f io.Writer) {
+	if cmd.backfill {
+		cmd.writeImplicitPrices(f)
+		return
+	}
 	if cmd.NArg() > 0 {
 		commodity := coin.Commodities[cmd.Arg(0)]
-		if (commodity == nil) {
+		if commodity == nil {
 			fmt.Fprintf(os.Stderr, "%s: unknown commodity\n", cmd.Arg(0))
 			return
 		}
+		if cmd.output != outText {
+			cmd.priceRows(commodity).write(f, cmd.output)
+			return
+		}
 		for _, ps := range commodity.Prices {
 			for _, p := range ps {
 				if cmd.location {
-					fmt.Fprintf(f, "%s %s %s %s\n", p.Time.Format(coin.DateFormat), p.Value.String(), p.Value.SafeId(false), p.Location())
+					fmt.Fprintf(f, "%s %s %s %s\n", p.Time.Format(coin.DateFormat), p.RateString(), p.Value.SafeId(false), p.Location())
 				} else {
-					fmt.Fprintf(f, "%s %s %s\n", p.Time.Format(coin.DateFormat), p.Value.String(), p.Value.SafeId(false))
+					fmt.Fprintf(f, "%s %s %s\n", p.Time.Format(coin.DateFormat), p.RateString(), p.Value.SafeId(false))
 				}
 			}
 		}
 		return
 	}
 	if cmd.getQuotes {
-		coin.CommoditiesDo(func(c *coin.Commodity) {
-			if !(c.NoMarket || c.Id == coin.DefaultCommodityId) {
-				var q *finance.Quote
-				var err error
-				if c.Symbol != "" {
-					q, err = quote.Get(c.Symbol)
-				} else {
-					var fx *finance.ForexPair
-					fx, err = forex.Get(c.Id + coin.DefaultCommodityId + "=X")
-					if err == nil {
-						q = &fx.Quote
-					}
-				}
-				if err != nil {
-					fmt.Fprintf(os.Stderr, "%s: %s\n", c.Id, err)
-					return
-				}
-				cur := coin.Commodities[q.CurrencyID]
-				if cur == nil {
-					fmt.Fprintf(os.Stderr, "%s: no commodity for %s\n", c.Id, q.CurrencyID)
-					return
-				}
-				amt := cur.NewAmountFloat(q.RegularMarketPrice)
-				var b strings.Builder
-				amt.Write(&b, false)
-				fmt.Fprintf(f, "P %s %s %s\n", time.Now().Format(coin.DateFormat), c.Id, b.String())
-			}
-		})
+		cmd.getPrices(f)
+		return
+	}
+	if cmd.output != outText {
+		cmd.rows().write(f, cmd.output)
 		return
 	}
 	coin.CommoditiesDo(func(c *coin.Commodity) {
@@ -102,10 +106,156 @@ func (cmd *cmdCommodities) execute(f io.Writer) {
 		} else {
 			sym := c.Symbol
 			dl := ' '
-			if len(sym) > 0 && !c.NoMarket {
+			if (len(sym) > 0 || c.Quote != "") && !c.NoMarket {
 				dl = 'Q'
 			}
 			fmt.Fprintf(f, "%10s | %10s | %c | %s\n", c.Id, sym, dl, c.Name)
 		}
 	})
 }
+
+// getPrices fetches current or historical quotes of all commodities
+// and prints or appends to the price files those that are not in the price database.
+func (cmd *cmdCommodities) getPrices(f io.Writer) {
+	var prices []*coin.Price
+	var skipped, unnamed []string
+	coin.CommoditiesDo(func(c *coin.Commodity) {
+		if c.Id == coin.DefaultCommodityId {
+			return
+		}
+		if c.NoMarket {
+			skipped = append(skipped, c.Id)
+			return
+		}
+		if c.Symbol == "" && c.Quote == "" {
+			unnamed = append(unnamed, c.Id)
+		}
+		qp, err := quoteProvider(c)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", c.Id, err)
+			return
+		}
+		var ps []*coin.Price
+		if cmd.from.IsZero() {
+			var p *coin.Price
+			if p, err = qp.Quote(c); err == nil {
+				ps = append(ps, p)
+			}
+		} else if ps, err = qp.History(c, cmd.from.Time); err == nil && cmd.monthly {
+			ps = priceList(ps).thin(today().AddDate(0, 0, 1), &month)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", c.Id, err)
+			return
+		}
+		prices = append(prices, ps...)
+	})
+	if len(skipped) > 0 {
+		fmt.Fprintf(os.Stderr, "nomarket: %s\n", strings.Join(skipped, ", "))
+	}
+	if len(unnamed) > 0 {
+		fmt.Fprintf(os.Stderr, "no symbol: %s\n", strings.Join(unnamed, ", "))
+	}
+	prices = newPrices(prices)
+	if cmd.write {
+		appendPrices(prices)
+	}
+	if cmd.output != outText {
+		rs := rows{{"Date", "Commodity", "Price", "Currency"}}
+		for _, p := range prices {
+			rs = append(rs, []string{p.Time.Format(coin.DateFormat), p.Commodity.Id, p.RateString(), p.Currency.Id})
+		}
+		rs.write(f, cmd.output)
+		return
+	}
+	for _, p := range prices {
+		err := p.Write(f, false)
+		check.NoError(err, "writing price %s", p.Commodity.Id)
+	}
+}
+
+// newPrices returns the prices that are not in the price database
+// (the same commodity, currency and date) ordered by date.
+func newPrices(prices []*coin.Price) (fresh []*coin.Price) {
+	key := func(p *coin.Price) string {
+		return p.CommodityId + " " + p.Value.Commodity.Id + " " + p.Time.Format(coin.DateFormat)
+	}
+	seen := map[string]bool{}
+	for _, p := range coin.Prices {
+		seen[key(p)] = true
+	}
+	for _, p := range prices {
+		if !seen[key(p)] {
+			seen[key(p)] = true
+			fresh = append(fresh, p)
+		}
+	}
+	sort.SliceStable(fresh, func(i, j int) bool {
+		return fresh[i].Time.Before(fresh[j].Time)
+	})
+	return fresh
+}
+
+// writeImplicitPrices writes the prices implied by transactions
+// in the prices file format ordered by date.
+func (cmd *cmdCommodities) writeImplicitPrices(f io.Writer) {
+	prices := append([]*coin.Price(nil), coin.ImplicitPrices...)
+	sort.SliceStable(prices, func(i, j int) bool {
+		return prices[i].Time.Before(prices[j].Time)
+	})
+	for _, p := range prices {
+		err := p.Write(f, true)
+		check.NoError(err, "writing price %s", p.Location())
+	}
+}
+
+func (cmd *cmdCommodities) rows() (rs rows) {
+	if cmd.prices {
+		rs = append(rs, []string{"Commodity", "Currency", "Date", "Price", "Count"})
+	} else {
+		rs = append(rs, []string{"Commodity", "Symbol", "Quote", "Name"})
+	}
+	coin.CommoditiesDo(func(c *coin.Commodity) {
+		if !cmd.prices {
+			quote := (len(c.Symbol) > 0 || c.Quote != "") && !c.NoMarket
+			rs = append(rs, []string{c.Id, c.Symbol, strconv.FormatBool(quote), c.Name})
+			return
+		}
+		for _, cur := range sortedCurrencies(c) {
+			ps := c.Prices[cur]
+			rs = append(rs, []string{
+				c.Id,
+				cur.Id,
+				ps[0].Time.Format(coin.DateFormat),
+				ps[0].RateString(),
+				strconv.Itoa(len(ps)),
+			})
+		}
+	})
+	return rs
+}
+
+func (cmd *cmdCommodities) priceRows(c *coin.Commodity) (rs rows) {
+	header := []string{"Date", "Price", "Currency"}
+	if cmd.location {
+		header = append(header, "Location")
+	}
+	rs = append(rs, header)
+	for _, cur := range sortedCurrencies(c) {
+		for _, p := range c.Prices[cur] {
+			row := []string{p.Time.Format(coin.DateFormat), p.RateString(), p.Value.Commodity.Id}
+			if cmd.location {
+				row = append(row, p.Location())
+			}
+			rs = append(rs, row)
+		}
+	}
+	return rs
+}
+
+// sortedCurrencies returns the currencies of commodity prices sorted by id
+func sortedCurrencies(c *coin.Commodity) []*coin.Commodity {
+	currencies := c.Currencies()
+	sort.Slice(currencies, func(i, j int) bool { return currencies[i].Id < currencies[j].Id })
+	return currencies
+}
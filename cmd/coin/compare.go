@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/mkobetic/coin"
+)
+
+func init() {
+	(&cmdCompare{}).newCommand("compare", "cmp")
+}
+
+type cmdCompare struct {
+	flagsWithUsage
+	begin1, end1 coin.Date // period A
+	begin2, end2 coin.Date // period B
+	monthly      bool
+	payee        string
+	tag          string
+	zeroBalance  bool
+	level        int
+	top          int
+	effective    bool
+	output       string
+}
+
+func (*cmdCompare) newCommand(names ...string) command {
+	var cmd cmdCompare
+	cmd.FlagSet = newCommand(&cmd, names...)
+	setUsage(cmd.FlagSet, `(compare|cmp) [flags] [account]
+
+Compares account totals for two periods, A and B (default: last year and this year).
+Period B defaults to the current year (or month with -m), period A defaults to period B a year earlier.
+Accounts are listed from the largest to the smallest change.`)
+	cmd.Var(&cmd.begin1, "b1", "begin period A from this date")
+	cmd.Var(&cmd.end1, "e1", "end period A on this date")
+	cmd.Var(&cmd.begin2, "b2", "begin period B from this date")
+	cmd.Var(&cmd.end2, "e2", "end period B on this date")
+	cmd.BoolVar(&cmd.monthly, "m", false, "compare this month with the same month last year")
+	cmd.StringVar(&cmd.payee, "p", "", "use only postings matching the payee (regex)")
+	cmd.StringVar(&cmd.tag, "t", "", "use only postings matching the tag[:value] (regex)")
+	cmd.BoolVar(&cmd.zeroBalance, "z", false, "list accounts with zero totals in both periods")
+	cmd.IntVar(&cmd.level, "l", 0, "list accounts up to this level, 0 means all")
+	cmd.IntVar(&cmd.top, "g", 0, "list only this many largest changes, 0 means all")
+	cmd.BoolVar(&cmd.effective, "effective", false, "use effective dates of postings (DATE=DATE2, [=DATE])")
+	outputFlag(cmd.FlagSet, &cmd.output)
+	return &cmd
+}
+
+func (cmd *cmdCompare) init() {
+	coin.LoadAll()
+}
+
+func (cmd *cmdCompare) execute(f io.Writer) {
+	account := coin.Root
+	if cmd.NArg() > 0 {
+		account = coin.MustFindAccount(cmd.Arg(0))
+	}
+	a, b := cmd.periods()
+	_, totalsA := accountBalances(account, func(ps []*coin.Posting) postings {
+		return cmd.trim(ps, a.begin, a.end)
+	})
+	_, totalsB := accountBalances(account, func(ps []*coin.Posting) postings {
+		return cmd.trim(ps, b.begin, b.end)
+	})
+	var changes []*change
+	account.WithChildrenDo(func(acc *coin.Account) {
+		if cmd.level != 0 && acc.Depth() > cmd.level {
+			return
+		}
+		c := newChange(acc, totalsA[acc], totalsB[acc])
+		if cmd.zeroBalance || !(c.a.IsZero() && c.b.IsZero()) {
+			changes = append(changes, c)
+		}
+	})
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].delta.IsBigger(changes[j].delta)
+	})
+	if cmd.top > 0 && len(changes) > cmd.top {
+		changes = changes[:cmd.top]
+	}
+	if cmd.output == outText {
+		printChanges(f, changes, a.String(), b.String())
+		return
+	}
+	compareRows(changes, a.String(), b.String()).write(f, cmd.output)
+}
+
+// periods returns the A and B periods to compare.
+func (cmd *cmdCompare) periods() (a, b period) {
+	b = period{begin: cmd.begin2.Time, end: cmd.end2.Time}
+	if b.begin.IsZero() {
+		if cmd.monthly {
+			b.begin = time.Date(coin.Year, time.Month(coin.Month), 1, 12, 0, 0, 0, time.UTC)
+		} else {
+			b.begin = time.Date(coin.Year, time.January, 1, 12, 0, 0, 0, time.UTC)
+		}
+	}
+	if b.end.IsZero() {
+		if cmd.monthly {
+			b.end = b.begin.AddDate(0, 1, 0)
+		} else {
+			b.end = b.begin.AddDate(1, 0, 0)
+		}
+	}
+	a = period{begin: cmd.begin1.Time, end: cmd.end1.Time}
+	if a.begin.IsZero() {
+		a.begin = b.begin.AddDate(-1, 0, 0)
+	}
+	if a.end.IsZero() {
+		a.end = b.end.AddDate(-1, 0, 0)
+	}
+	return a, b
+}
+
+func (cmd *cmdCompare) trim(ps []*coin.Posting, begin, end time.Time) postings {
+	ps = trim(ps, coin.Date{Time: begin}, coin.Date{Time: end}, cmd.effective)
+	if len(cmd.payee) > 0 {
+		var pps []*coin.Posting
+		r := regexp.MustCompile("(?i)" + cmd.payee)
+		for _, p := range ps {
+			if r.MatchString(p.Transaction.Description) {
+				pps = append(pps, p)
+			}
+		}
+		ps = pps
+	}
+	if len(cmd.tag) > 0 {
+		var pps []*coin.Posting
+		r := coin.NewTagMatcher(cmd.tag)
+		for _, p := range ps {
+			if r.Match(p.Tags) || r.Match(p.Transaction.Tags) {
+				pps = append(pps, p)
+			}
+		}
+		ps = pps
+	}
+	return postings(ps)
+}
+
+// period is a time range [begin, end)
+type period struct {
+	begin, end time.Time
+}
+
+// String returns the shortest label that describes the period,
+// e.g. 2010 for a calendar year, 2010/03 for a calendar month.
+func (p period) String() string {
+	y, m, d := p.begin.Date()
+	switch {
+	case m == time.January && d == 1 && p.end.Equal(p.begin.AddDate(1, 0, 0)):
+		return fmt.Sprint(y)
+	case d == 1 && p.end.Equal(p.begin.AddDate(0, 1, 0)):
+		return p.begin.Format(coin.MonthFormat)
+	}
+	return p.begin.Format(coin.DateFormat) + "-" + p.end.Format(coin.DateFormat)
+}
+
+// change captures account totals for periods A and B
+type change struct {
+	acc   *coin.Account
+	a, b  *coin.Amount
+	delta *coin.Amount
+}
+
+func newChange(acc *coin.Account, a, b *coin.Amount) *change {
+	delta := b.Copy()
+	delta.Sub(delta.Int, a.Int)
+	return &change{acc: acc, a: a, b: b, delta: delta}
+}
+
+// percent returns the relative change from A to B,
+// empty if A is zero.
+func (c *change) percent() string {
+	if c.a.IsZero() {
+		return ""
+	}
+	r := new(big.Rat).SetFrac(c.delta.Int, new(big.Int).Abs(c.a.Int))
+	r.Mul(r, big.NewRat(100, 1))
+	return r.FloatString(1) + "%"
+}
+
+func printChanges(f io.Writer, changes []*change, labelA, labelB string) {
+	widths := [5]int{len(labelA), len(labelB), len("Change"), len("%")}
+	for _, c := range changes {
+		decimals := c.acc.Commodity.Decimals
+		widths[0] = max(widths[0], c.a.Width(decimals))
+		widths[1] = max(widths[1], c.b.Width(decimals))
+		widths[2] = max(widths[2], c.delta.Width(decimals))
+		widths[3] = max(widths[3], len(c.percent()))
+		widths[4] = max(widths[4], len(c.acc.CommodityId))
+	}
+	fmt.Fprintf(f, "%*s | %*s | %*s | %*s | %-*s | %s\n",
+		widths[0], labelA, widths[1], labelB, widths[2], "Change", widths[3], "%", widths[4], "", "Account")
+	for _, c := range changes {
+		fmt.Fprintf(f, "%*a | %*a | %*a | %*s | %-*s | %s\n",
+			widths[0], c.a, widths[1], c.b, widths[2], c.delta, widths[3], c.percent(),
+			widths[4], c.acc.CommodityId, c.acc.FullName)
+	}
+}
+
+func compareRows(changes []*change, labelA, labelB string) (rs rows) {
+	rs = append(rs, []string{"Account", labelA, labelB, "Change", "%", "Commodity"})
+	for _, c := range changes {
+		rs = append(rs, []string{
+			c.acc.FullName,
+			c.a.String(),
+			c.b.String(),
+			c.delta.String(),
+			c.percent(),
+			c.acc.CommodityId,
+		})
+	}
+	return rs
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"io"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mkobetic/coin"
+	"github.com/mkobetic/coin/check"
+	"github.com/mkobetic/coin/check/warn"
+)
+
+func init() {
+	(&cmdDividends{}).newCommand("dividends", "div")
+}
+
+type cmdDividends struct {
+	flagsWithUsage
+	begin, end coin.Date
+	tag        string
+	effective  bool
+	output     string
+}
+
+func (*cmdDividends) newCommand(names ...string) command {
+	var cmd cmdDividends
+	cmd.FlagSet = newCommand(&cmd, names...)
+	setUsage(cmd.FlagSet, `(dividends|div) [flags] [account]
+
+Lists income postings of account and its subaccounts (default: Income) by year
+and by the commodity that generated them, with yield on cost and yield on market value
+at the end of the year. The commodity is identified by the posting (or transaction) tag
+(default: #security: ID) or by the name of the income account, e.g. Income:Dividends:VGRO.
+Values are in the default commodity.`)
+	cmd.Var(&cmd.begin, "b", "begin with postings from this date")
+	cmd.Var(&cmd.end, "e", "end with postings before this date")
+	cmd.StringVar(&cmd.tag, "t", "security", "name of the tag identifying the commodity")
+	cmd.BoolVar(&cmd.effective, "effective", false, "use effective dates of postings (DATE=DATE2, [=DATE])")
+	outputFlag(cmd.FlagSet, &cmd.output)
+	return &cmd
+}
+
+func (cmd *cmdDividends) init() {
+	coin.LoadAll()
+}
+
+func (cmd *cmdDividends) execute(f io.Writer) {
+	account := coin.AccountsByName["Income"]
+	if cmd.NArg() > 0 {
+		account = coin.MustFindAccount(cmd.Arg(0))
+	}
+	check.If(account != nil, "cannot find Income account\n")
+	ds := cmd.dividends(account)
+	dividendRows(ds).write(f, cmd.output)
+}
+
+// dividend is the income generated by a commodity in a year
+type dividend struct {
+	year      time.Time
+	commodity *coin.Commodity // nil if unknown
+	income    *coin.Amount
+	cost      *coin.Amount // cost basis at the end of the year, nil if unknown
+	value     *coin.Amount // market value at the end of the year, nil if unknown
+}
+
+func (d *dividend) security() string {
+	if d.commodity == nil {
+		return "(none)"
+	}
+	return d.commodity.Id
+}
+
+// dividends returns the income of the account postings by year and commodity
+func (cmd *cmdDividends) dividends(account *coin.Account) (ds []*dividend) {
+	dc := coin.DefaultCommodity()
+	type key struct {
+		year      time.Time
+		commodity *coin.Commodity
+	}
+	byKey := map[key]*dividend{}
+	account.WithChildrenDo(func(a *coin.Account) {
+		for _, p := range trim(a.Postings, cmd.begin, cmd.end, cmd.effective) {
+			// prices posted on the transaction date are included
+			income, err := dc.ConvertAt(p.Quantity, p.Quantity.Commodity, p.Transaction.Posted.AddDate(0, 0, 1))
+			if err != nil {
+				warn.If(true, "%s: %s, income excluded\n", p.Transaction.Location(), err)
+				continue
+			}
+			k := key{year.reduce(p.Date(cmd.effective)), cmd.commodity(p)}
+			d := byKey[k]
+			if d == nil {
+				d = &dividend{year: k.year, commodity: k.commodity, income: coin.NewZeroAmount(dc)}
+				byKey[k] = d
+				ds = append(ds, d)
+			}
+			// income postings are credits
+			d.income.Sub(d.income.Int, income.Int)
+		}
+	})
+	holdings := map[*coin.Commodity][]*coin.Account{}
+	coin.Root.WithChildrenDo(func(a *coin.Account) {
+		holdings[a.Commodity] = append(holdings[a.Commodity], a)
+	})
+	for _, d := range ds {
+		if d.commodity == nil {
+			continue
+		}
+		end := nextPeriod(&year, d.year)
+		d.cost = costBasis(d.commodity, holdings[d.commodity], end, false, cmd.effective)
+		quantity := coin.NewZeroAmount(d.commodity)
+		for _, a := range holdings[d.commodity] {
+			quantity.Add(quantity.Int, a.BalanceAt(end, cmd.effective).Int)
+		}
+		if value, err := dc.ConvertAt(quantity, d.commodity, end); err == nil {
+			d.value = value
+		}
+	}
+	sort.SliceStable(ds, func(i, j int) bool {
+		if !ds[i].year.Equal(ds[j].year) {
+			return ds[i].year.Before(ds[j].year)
+		}
+		return ds[i].security() < ds[j].security()
+	})
+	return ds
+}
+
+// commodity returns the commodity that generated the income posting,
+// nil if it cannot be determined.
+func (cmd *cmdDividends) commodity(p *coin.Posting) *coin.Commodity {
+	id := p.Tags.Value(cmd.tag)
+	if !p.Tags.Includes(cmd.tag) {
+		id = p.Transaction.Tags.Value(cmd.tag)
+	}
+	if id != "" {
+		c := coin.Commodities[strings.TrimSpace(id)]
+		warn.If(c == nil, "%s: unknown commodity %s\n", p.Transaction.Location(), id)
+		return c
+	}
+	return coin.Commodities[p.Account.Name]
+}
+
+// yield returns the income as percentage of the base amount,
+// empty if base is unknown or not positive.
+func yield(income, base *coin.Amount) string {
+	if base == nil || base.Sign() <= 0 {
+		return ""
+	}
+	r := new(big.Rat).SetFrac(income.Int, base.Int)
+	r.Mul(r, big.NewRat(100, 1))
+	return r.FloatString(2) + "%"
+}
+
+var dividendsHeader = []string{"Year", "Security", "Income", "Cost", "Yield on cost", "Value", "Yield"}
+
+func (d *dividend) cells() []string {
+	return []string{
+		d.year.Format(year.format),
+		d.security(),
+		d.income.String(),
+		optional(d.cost),
+		yield(d.income, d.cost),
+		optional(d.value),
+		yield(d.income, d.value),
+	}
+}
+
+func dividendRows(ds []*dividend) (rs rows) {
+	rs = append(rs, append(append([]string{}, dividendsHeader...), "Commodity"))
+	for _, d := range ds {
+		rs = append(rs, append(d.cells(), coin.DefaultCommodity().Id))
+	}
+	return rs
+}
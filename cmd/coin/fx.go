@@ -0,0 +1,165 @@
+package main
+
+import (
+	"io"
+	"sort"
+	"time"
+
+	"github.com/mkobetic/coin"
+	"github.com/mkobetic/coin/check"
+	"github.com/mkobetic/coin/check/warn"
+)
+
+func init() {
+	(&cmdFX{}).newCommand("fx")
+}
+
+type cmdFX struct {
+	flagsWithUsage
+	begin, end        coin.Date
+	weekly, monthly   bool
+	quarterly, yearly bool
+	effective         bool
+	output            string
+}
+
+func (*cmdFX) newCommand(names ...string) command {
+	var cmd cmdFX
+	cmd.FlagSet = newCommand(&cmd, names...)
+	setUsage(cmd.FlagSet, `fx [flags] [account]
+
+Lists unrealized foreign exchange gain or loss of accounts held in currencies other
+than the default commodity in account and its subaccounts (default: Assets) at the end
+of each period (default: monthly). Currencies are commodities of class currency
+or commodities used as currency of prices. The book value uses the historical rates
+of the conversion postings (or the prices of the transaction date if there isn't one),
+the market value uses the latest prices before the end of the period.`)
+	cmd.Var(&cmd.begin, "b", "begin with the period including this date (default: first posting)")
+	cmd.Var(&cmd.end, "e", "end with the period before this date (default: last posting)")
+	cmd.BoolVar(&cmd.weekly, "w", false, "list gains by week")
+	cmd.BoolVar(&cmd.monthly, "m", false, "list gains by month")
+	cmd.BoolVar(&cmd.quarterly, "q", false, "list gains by quarter")
+	cmd.BoolVar(&cmd.yearly, "y", false, "list gains by year")
+	cmd.BoolVar(&cmd.effective, "effective", false, "use effective dates of postings (DATE=DATE2, [=DATE])")
+	outputFlag(cmd.FlagSet, &cmd.output)
+	return &cmd
+}
+
+func (cmd *cmdFX) init() {
+	coin.LoadAll()
+}
+
+func (cmd *cmdFX) execute(f io.Writer) {
+	account := coin.AccountsByName["Assets"]
+	if cmd.NArg() > 0 {
+		account = coin.MustFindAccount(cmd.Arg(0))
+	}
+	check.If(account != nil, "cannot find Assets account\n")
+	dc := coin.DefaultCommodity()
+	currencies := currencies()
+	byCurrency := map[*coin.Commodity][]*coin.Account{}
+	var accounts []*coin.Account
+	account.WithChildrenDo(func(a *coin.Account) {
+		if a.Commodity != dc && currencies[a.Commodity] && len(a.Postings) > 0 {
+			byCurrency[a.Commodity] = append(byCurrency[a.Commodity], a)
+			accounts = append(accounts, a)
+		}
+	})
+	check.If(len(accounts) > 0, "no foreign currency accounts to report\n")
+	var ordered []*coin.Commodity
+	for c := range byCurrency {
+		ordered = append(ordered, c)
+	}
+	sort.Slice(ordered, func(i, j int) bool { return ordered[i].Id < ordered[j].Id })
+
+	by := cmd.period()
+	first, last := timeRange(cmd.begin, cmd.end, cmd.effective, accounts...)
+	var gains []*fxGain
+	previous := map[*coin.Commodity]*coin.Amount{}
+	for start := by.reduce(first); start.Before(last); start = nextPeriod(by, start) {
+		at := nextPeriod(by, start)
+		if !cmd.end.IsZero() && cmd.end.Before(at) {
+			at = cmd.end.Time
+		}
+		for _, c := range ordered {
+			g := newFXGain(start.Format(by.format), c, byCurrency[c], at, cmd.effective)
+			if g == nil {
+				continue
+			}
+			g.change = g.gain.Copy()
+			if p := previous[c]; p != nil {
+				g.change.Sub(g.change.Int, p.Int)
+			}
+			previous[c] = g.gain
+			gains = append(gains, g)
+		}
+	}
+	fxGainRows(gains).write(f, cmd.output)
+}
+
+func (cmd *cmdFX) period() *reducer {
+	switch {
+	case cmd.weekly:
+		return &week
+	case cmd.quarterly:
+		return &quarter
+	case cmd.yearly:
+		return &year
+	}
+	return &month
+}
+
+// fxGain is the unrealized gain of the foreign currency balance at the end of a period
+type fxGain struct {
+	period  string
+	balance *coin.Amount // in the foreign currency
+	book    *coin.Amount // value at historical rates
+	market  *coin.Amount // value at the period end rates
+	gain    *coin.Amount // market - book
+	change  *coin.Amount // change of gain from the previous period
+}
+
+// newFXGain returns the gain of the currency balance of the accounts before time at,
+// nil if it cannot be determined.
+func newFXGain(period string, c *coin.Commodity, accounts []*coin.Account, at time.Time, effective bool) *fxGain {
+	dc := coin.DefaultCommodity()
+	g := &fxGain{period: period, balance: coin.NewZeroAmount(c)}
+	for _, a := range accounts {
+		g.balance.Add(g.balance.Int, a.BalanceAt(at, effective).Int)
+	}
+	g.book = costBasis(c, accounts, at, true, effective)
+	if g.book == nil {
+		return nil
+	}
+	market, err := dc.ConvertAt(g.balance, c, at)
+	if err != nil {
+		warn.If(true, "%s: %s on %s, excluded\n", c.Id, err, at.Format(coin.DateFormat))
+		return nil
+	}
+	g.market = market
+	g.gain = g.market.Copy()
+	g.gain.Sub(g.gain.Int, g.book.Int)
+	return g
+}
+
+var fxGainsHeader = []string{"Date", "Balance", "Currency", "Book value", "Market value", "Gain", "Change"}
+
+func (g *fxGain) cells() []string {
+	return []string{
+		g.period,
+		g.balance.String(),
+		g.balance.Commodity.Id,
+		g.book.String(),
+		g.market.String(),
+		g.gain.String(),
+		g.change.String(),
+	}
+}
+
+func fxGainRows(gains []*fxGain) (rs rows) {
+	rs = append(rs, append(append([]string{}, fxGainsHeader...), "Commodity"))
+	for _, g := range gains {
+		rs = append(rs, append(g.cells(), coin.DefaultCommodity().Id))
+	}
+	return rs
+}
@@ -6,6 +6,9 @@ import (
 	"io"
 	"os"
 	"sort"
+
+	"github.com/mkobetic/coin"
+	"github.com/mkobetic/coin/check"
 )
 
 var (
@@ -50,6 +53,7 @@ func setUsage(fs *flag.FlagSet, usage string) {
 }
 
 func main() {
+	check.NoError(coin.SetOutputLocale(), "invalid COINLOCALE")
 	// resort commands alphabetically,
 	// (needs to happen after they are all defined)
 	sort.Slice(commands, func(i, j int) bool {
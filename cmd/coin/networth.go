@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/mkobetic/coin"
+	"github.com/mkobetic/coin/check"
+	"github.com/mkobetic/coin/check/warn"
+)
+
+func init() {
+	(&cmdNetWorth{}).newCommand("networth", "nw")
+}
+
+type cmdNetWorth struct {
+	flagsWithUsage
+	begin, end        coin.Date
+	weekly, monthly   bool
+	quarterly, yearly bool
+	assets            string
+	liabilities       string
+	effective         bool
+	output            string
+}
+
+func (*cmdNetWorth) newCommand(names ...string) command {
+	var cmd cmdNetWorth
+	cmd.FlagSet = newCommand(&cmd, names...)
+	setUsage(cmd.FlagSet, `(networth|nw) [flags]
+
+Lists total assets, liabilities and net worth at the end of each period (default: monthly),
+converted to the default commodity using the latest prices before the end of the period.`)
+	cmd.Var(&cmd.begin, "b", "begin with the period including this date (default: first posting)")
+	cmd.Var(&cmd.end, "e", "end with the period before this date (default: last posting)")
+	cmd.BoolVar(&cmd.weekly, "w", false, "list net worth by week")
+	cmd.BoolVar(&cmd.monthly, "m", false, "list net worth by month")
+	cmd.BoolVar(&cmd.quarterly, "q", false, "list net worth by quarter")
+	cmd.BoolVar(&cmd.yearly, "y", false, "list net worth by year")
+	cmd.StringVar(&cmd.assets, "assets", "Assets", "assets account")
+	cmd.StringVar(&cmd.liabilities, "liabilities", "Liabilities", "liabilities account")
+	cmd.BoolVar(&cmd.effective, "effective", false, "use effective dates of postings (DATE=DATE2, [=DATE])")
+	outputFlag(cmd.FlagSet, &cmd.output)
+	return &cmd
+}
+
+func (cmd *cmdNetWorth) init() {
+	coin.LoadAll()
+}
+
+// worth is the net worth at the end of a period
+type worth struct {
+	period                   string
+	assets, liabilities, net *coin.Amount
+	change                   *coin.Amount
+}
+
+func (cmd *cmdNetWorth) execute(f io.Writer) {
+	by := cmd.period()
+	assets, liabilities := coin.AccountsByName[cmd.assets], coin.AccountsByName[cmd.liabilities]
+	check.If(assets != nil || liabilities != nil, "cannot find %s or %s account\n", cmd.assets, cmd.liabilities)
+	first, last := timeRange(cmd.begin, cmd.end, cmd.effective, assets, liabilities)
+	check.If(!first.IsZero() && !last.IsZero(), "no postings to report\n")
+	var worths []*worth
+	var previous *coin.Amount
+	for start := by.reduce(first); start.Before(last); start = nextPeriod(by, start) {
+		at := nextPeriod(by, start)
+		if !cmd.end.IsZero() && cmd.end.Before(at) {
+			at = cmd.end.Time
+		}
+		w := &worth{
+			period:      start.Format(by.format),
+			assets:      valueAt(assets, at, cmd.effective),
+			liabilities: valueAt(liabilities, at, cmd.effective),
+		}
+		w.net = w.assets.Copy()
+		w.net.Add(w.net.Int, w.liabilities.Int)
+		w.change = coin.NewZeroAmount(w.net.Commodity)
+		if previous != nil {
+			w.change.Sub(w.net.Int, previous.Int)
+		}
+		previous = w.net
+		worths = append(worths, w)
+	}
+	if cmd.output == outText {
+		printWorths(f, worths)
+		return
+	}
+	worthRows(worths).write(f, cmd.output)
+}
+
+func (cmd *cmdNetWorth) period() *reducer {
+	switch {
+	case cmd.weekly:
+		return &week
+	case cmd.quarterly:
+		return &quarter
+	case cmd.yearly:
+		return &year
+	}
+	return &month
+}
+
+// timeRange returns the time range from begin to end,
+// defaulting to the time range of the account postings
+// using their effective dates if effective is set.
+func timeRange(begin, end coin.Date, effective bool, accounts ...*coin.Account) (first, last time.Time) {
+	first, last = begin.Time, end.Time
+	for _, acc := range accounts {
+		if acc == nil {
+			continue
+		}
+		acc.WithChildrenDo(func(a *coin.Account) {
+			if len(a.Postings) == 0 {
+				return
+			}
+			ps := a.Postings
+			if !effective {
+				// postings are ordered by posted dates
+				ps = []*coin.Posting{ps[0], ps[len(ps)-1]}
+			}
+			for _, p := range ps {
+				if t := p.Date(effective); begin.IsZero() && (first.IsZero() || t.Before(first)) {
+					first = t
+				}
+				if t := p.Date(effective); end.IsZero() && !t.Before(last) {
+					last = t.Add(time.Nanosecond)
+				}
+			}
+		})
+	}
+	return first, last
+}
+
+// nextPeriod returns the start of the period following the period starting at start.
+func nextPeriod(by *reducer, start time.Time) time.Time {
+	next := start
+	for by.reduce(next).Equal(start) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return by.reduce(next)
+}
+
+// valueAt returns the balance of the account and its subaccounts before time at,
+// converted to the default commodity. Uses the effective dates of the postings if effective is set.
+func valueAt(acc *coin.Account, at time.Time, effective bool) *coin.Amount {
+	return valueWithPricesAt(acc, at, at, effective)
+}
+
+// valueWithPricesAt returns the balance of acc and its children before at
+// converted to the default commodity using the latest prices before pricesAt.
+func valueWithPricesAt(acc *coin.Account, at, pricesAt time.Time, effective bool) *coin.Amount {
+	dc := coin.DefaultCommodity()
+	total := coin.NewZeroAmount(dc)
+	if acc == nil {
+		return total
+	}
+	acc.WithChildrenDo(func(a *coin.Account) {
+		balance := a.BalanceAt(at, effective)
+		if balance.IsZero() {
+			return
+		}
+		converted, err := dc.ConvertAt(balance, a.Commodity, pricesAt)
+		if err != nil {
+			warn.If(true, "%s: %s on %s, excluded\n", a.FullName, err, at.Format(coin.DateFormat))
+			return
+		}
+		err = total.AddIn(converted)
+		check.NoError(err, "adding %s balance", a.FullName)
+	})
+	return total
+}
+
+func printWorths(f io.Writer, worths []*worth) {
+	widths := [5]int{0, len("Assets"), len("Liabilities"), len("Net worth"), len("Change")}
+	for _, w := range worths {
+		decimals := w.net.Commodity.Decimals
+		widths[0] = max(widths[0], len(w.period))
+		widths[1] = max(widths[1], w.assets.Width(decimals))
+		widths[2] = max(widths[2], w.liabilities.Width(decimals))
+		widths[3] = max(widths[3], w.net.Width(decimals))
+		widths[4] = max(widths[4], w.change.Width(decimals))
+	}
+	fmt.Fprintf(f, "%*s | %*s | %*s | %*s | %*s\n",
+		widths[0], "", widths[1], "Assets", widths[2], "Liabilities", widths[3], "Net worth", widths[4], "Change")
+	for _, w := range worths {
+		fmt.Fprintf(f, "%*s | %*a | %*a | %*a | %*a %s\n",
+			widths[0], w.period, widths[1], w.assets, widths[2], w.liabilities,
+			widths[3], w.net, widths[4], w.change, w.net.Commodity.Id)
+	}
+}
+
+func worthRows(worths []*worth) (rs rows) {
+	rs = append(rs, []string{"Date", "Assets", "Liabilities", "Net worth", "Change", "Commodity"})
+	for _, w := range worths {
+		rs = append(rs, []string{
+			w.period,
+			w.assets.String(),
+			w.liabilities.String(),
+			w.net.String(),
+			w.change.String(),
+			w.net.Commodity.Id,
+		})
+	}
+	return rs
+}
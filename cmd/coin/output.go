@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mkobetic/coin"
+	"github.com/mkobetic/coin/check"
+)
+
+// Output formats shared by all commands.
+// Text output is specific to each command,
+// the structured formats are produced from rows.
+const (
+	outText     = "text"
+	outJSON     = "json"
+	outCSV      = "csv"
+	outMarkdown = "markdown"
+)
+
+var outputFormats = []string{outText, outJSON, outCSV, outMarkdown}
+
+// outputFlag defines the -o flag selecting the output format,
+// extra formats are appended to the common ones.
+func outputFlag(fs *flag.FlagSet, output *string, extra ...string) {
+	formats := append(outputFormats[:len(outputFormats):len(outputFormats)], extra...)
+	fs.StringVar(output, "o", outText, "output format: "+strings.Join(formats, ", "))
+}
+
+// rows represent tabular output, the first row is the header.
+// All columns are strings, amounts are formatted without commodity,
+// which is listed in a separate column where needed.
+// Numbers and dates are written in the ledger syntax,
+// the text, csv and markdown formats present them in the coin.Output locale.
+type rows [][]string
+
+var (
+	decimalREX = regexp.MustCompile(`^-?\d+(\.\d+)?%?$`)
+	dateREX    = regexp.MustCompile(`^\d{4}/\d{2}/\d{2}$`)
+)
+
+// localize presents a cell with a number or a date in the coin.Output locale
+func localize(cell string) string {
+	if decimalREX.MatchString(cell) {
+		return coin.Output.Number(cell)
+	}
+	if dateREX.MatchString(cell) {
+		if t, err := time.Parse(coin.DateFormat, cell); err == nil {
+			return t.Format(coin.Output.DateFormat)
+		}
+	}
+	return cell
+}
+
+// localized returns the row with the cells localized
+func localized(r []string) []string {
+	cells := make([]string, len(r))
+	for i, c := range r {
+		cells[i] = localize(c)
+	}
+	return cells
+}
+
+// write rows in the specified format
+func (rs rows) write(f io.Writer, format string) {
+	switch format {
+	case outText:
+		rs.writeText(f)
+	case outJSON:
+		rs.writeJSON(f)
+	case outCSV:
+		rs.writeCSV(f)
+	case outMarkdown:
+		rs.writeMarkdown(f)
+	default:
+		check.If(false, "unknown output format %s", format)
+	}
+}
+
+func (rs rows) writeCSV(f io.Writer) {
+	w := csv.NewWriter(f)
+	if coin.Output.Decimal == "," {
+		w.Comma = ';'
+	}
+	for _, r := range rs {
+		w.Write(localized(r))
+	}
+	w.Flush()
+}
+
+func (rs rows) writeJSON(f io.Writer) {
+	w := json.NewEncoder(f)
+	for _, r := range rs {
+		w.Encode(r)
+	}
+}
+
+var numberREX = regexp.MustCompile(`^-?[\d,]*\.?\d+%?$`)
+
+// writeMarkdown writes rows as a markdown table,
+// columns with only numbers in them are right aligned.
+func (rs rows) writeMarkdown(f io.Writer) {
+	if len(rs) == 0 {
+		return
+	}
+	header, body := rs[0], rs[1:]
+	escape := strings.NewReplacer("|", `\|`, "\n", " ")
+	line := func(cells []string) {
+		var b strings.Builder
+		b.WriteString("|")
+		for _, c := range cells {
+			b.WriteString(" ")
+			b.WriteString(escape.Replace(c))
+			b.WriteString(" |")
+		}
+		fmt.Fprintln(f, b.String())
+	}
+	line(localized(header))
+	align := make([]string, len(header))
+	for i := range header {
+		align[i] = "---"
+		if rs.numeric(i) {
+			align[i] = "---:"
+		}
+	}
+	line(align)
+	for _, r := range body {
+		line(localized(r))
+	}
+}
+
+// numeric returns true if column i has only numbers in it
+func (rs rows) numeric(i int) (numeric bool) {
+	for _, r := range rs[1:] {
+		if i >= len(r) || r[i] == "" {
+			continue
+		}
+		if numeric = numberREX.MatchString(r[i]); !numeric {
+			return false
+		}
+	}
+	return numeric
+}
+
+// writeText writes rows as a text table with columns separated by |,
+// columns with only numbers in them are right aligned.
+func (rs rows) writeText(f io.Writer) {
+	if len(rs) == 0 {
+		return
+	}
+	cells := make([][]string, len(rs))
+	widths := make([]int, len(rs[0]))
+	for i, r := range rs {
+		cells[i] = localized(r)
+		for j, c := range cells[i] {
+			widths[j] = max(widths[j], utf8.RuneCountInString(c))
+		}
+	}
+	for _, r := range cells {
+		for i, c := range r {
+			if rs.numeric(i) {
+				r[i] = fmt.Sprintf("%*s", widths[i], c)
+			} else {
+				r[i] = fmt.Sprintf("%-*s", widths[i], c)
+			}
+		}
+		fmt.Fprintln(f, strings.TrimRight(strings.Join(r, " | "), " "))
+	}
+}
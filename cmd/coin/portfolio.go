@@ -0,0 +1,348 @@
+package main
+
+import (
+	"io"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mkobetic/coin"
+	"github.com/mkobetic/coin/check"
+	"github.com/mkobetic/coin/check/warn"
+)
+
+func init() {
+	(&cmdPortfolio{}).newCommand("portfolio", "port")
+}
+
+type cmdPortfolio struct {
+	flagsWithUsage
+	classes bool
+	output  string
+}
+
+func (*cmdPortfolio) newCommand(names ...string) command {
+	var cmd cmdPortfolio
+	cmd.FlagSet = newCommand(&cmd, names...)
+	setUsage(cmd.FlagSet, `(portfolio|port) [flags] [account]
+
+Lists holdings of non-currency commodities in account and its subaccounts (default: Root)
+grouped by commodity, with the latest price, market value, cost basis, unrealized gain
+and allocation. Values are in the default commodity.`)
+	cmd.BoolVar(&cmd.classes, "c", false, "list allocation by commodity class instead of holdings")
+	outputFlag(cmd.FlagSet, &cmd.output)
+	return &cmd
+}
+
+func (cmd *cmdPortfolio) init() {
+	coin.LoadAll()
+}
+
+func (cmd *cmdPortfolio) execute(f io.Writer) {
+	account := coin.Root
+	if cmd.NArg() > 0 {
+		account = coin.MustFindAccount(cmd.Arg(0))
+	}
+	holdings := newHoldings(account)
+	if cmd.classes {
+		holdings.classes().rows(holdings.value).write(f, cmd.output)
+		return
+	}
+	holdings.rows().write(f, cmd.output)
+}
+
+// holding is the total quantity of a commodity held in the portfolio accounts
+type holding struct {
+	commodity *coin.Commodity
+	quantity  *coin.Amount
+	price     *coin.Price  // latest price, nil if there isn't one
+	value     *coin.Amount // market value, nil if it cannot be determined
+	cost      *coin.Amount // average cost basis, nil if unknown
+}
+
+// gain returns the unrealized gain, nil if value or cost is unknown
+func (h *holding) gain() *coin.Amount {
+	if h.value == nil || h.cost == nil {
+		return nil
+	}
+	gain := h.value.Copy()
+	gain.Sub(gain.Int, h.cost.Int)
+	return gain
+}
+
+type holdings struct {
+	all   []*holding
+	value *coin.Amount // total market value
+	cost  *coin.Amount // total cost basis, nil if unknown for any holding
+}
+
+// newHoldings collects the holdings of the accounts that hold non-currency commodities.
+func newHoldings(account *coin.Account) *holdings {
+	dc := coin.DefaultCommodity()
+	currencies := currencies()
+	byCommodity := map[*coin.Commodity][]*coin.Account{}
+	account.WithChildrenDo(func(a *coin.Account) {
+		if !currencies[a.Commodity] && len(a.Postings) > 0 {
+			byCommodity[a.Commodity] = append(byCommodity[a.Commodity], a)
+		}
+	})
+	hs := &holdings{value: coin.NewZeroAmount(dc), cost: coin.NewZeroAmount(dc)}
+	for c, accounts := range byCommodity {
+		h := &holding{commodity: c, quantity: coin.NewZeroAmount(c)}
+		for _, a := range accounts {
+			err := h.quantity.AddIn(a.Balance())
+			check.NoError(err, "adding %s balance", a.FullName)
+		}
+		if h.quantity.IsZero() {
+			continue
+		}
+		h.price = latestPrice(c, dc)
+		if value, err := dc.Convert(h.quantity, c); err == nil {
+			h.value = value
+			hs.value.Add(hs.value.Int, value.Int)
+		} else {
+			warn.If(true, "%s: %s, value excluded\n", c.Id, err)
+		}
+		h.cost = costBasis(c, accounts, time.Time{}, false, false)
+		if h.cost != nil && hs.cost != nil {
+			hs.cost.Add(hs.cost.Int, h.cost.Int)
+		} else {
+			hs.cost = nil
+		}
+		hs.all = append(hs.all, h)
+	}
+	sort.Slice(hs.all, func(i, j int) bool {
+		return hs.all[i].commodity.Id < hs.all[j].commodity.Id
+	})
+	return hs
+}
+
+// currencies returns the default commodity, the commodities of class currency
+// and the commodities that are used as currency of any price.
+func currencies() map[*coin.Commodity]bool {
+	currencies := map[*coin.Commodity]bool{coin.DefaultCommodity(): true}
+	for _, c := range coin.Commodities {
+		if c.Class == "currency" {
+			currencies[c] = true
+		}
+		for _, cur := range c.Currencies() {
+			currencies[cur] = true
+		}
+	}
+	return currencies
+}
+
+// latestPrice returns the latest price of c in dc,
+// or the latest price in any other currency if there isn't one.
+func latestPrice(c, dc *coin.Commodity) (latest *coin.Price) {
+	if ps := c.Prices[dc]; len(ps) > 0 {
+		return ps[0]
+	}
+	for _, ps := range c.Prices {
+		if latest == nil || ps[0].Time.After(latest.Time) {
+			latest = ps[0]
+		}
+	}
+	return latest
+}
+
+// costBasis returns the average cost basis of commodity c held in the accounts,
+// in the default commodity. The cost of an acquisition is the amount of the other
+// commodity postings of the transaction, converted at the prices of the transaction date.
+// Postings to expense accounts (fees) are excluded, the fees are part of the amount paid.
+// Disposals reduce the cost basis proportionally. Only transactions dated before
+// time at are included, unless at is zero, using the effective dates of the account
+// postings if effective is set. Acquisitions without other commodity postings
+// are valued at the prices of the transaction date if atRate is set,
+// otherwise nil is returned because the cost cannot be determined.
+func costBasis(c *coin.Commodity, accounts []*coin.Account, at time.Time, atRate, effective bool) *coin.Amount {
+	dc := coin.DefaultCommodity()
+	holding := map[*coin.Account]bool{}
+	var transactions []*coin.Transaction
+	dates := map[*coin.Transaction]time.Time{}
+	for _, a := range accounts {
+		holding[a] = true
+		for _, p := range a.Postings {
+			if _, seen := dates[p.Transaction]; !seen {
+				dates[p.Transaction] = p.Time(effective)
+				transactions = append(transactions, p.Transaction)
+			}
+		}
+	}
+	sort.SliceStable(transactions, func(i, j int) bool {
+		return dates[transactions[i]].Before(dates[transactions[j]])
+	})
+	held, cost := coin.NewZeroAmount(c), coin.NewZeroAmount(dc)
+	for _, t := range transactions {
+		if !at.IsZero() && !dates[t].Before(at) {
+			break
+		}
+		quantity := coin.NewZeroAmount(c)
+		var payments []*coin.Posting
+		for _, p := range t.Postings {
+			switch {
+			case holding[p.Account]:
+				err := quantity.AddIn(p.Quantity)
+				check.NoError(err, "adding %s quantity", t.Location())
+			case p.Quantity.Commodity == c:
+				// transfers from accounts that are not included
+			case isExpense(p.Account):
+				// fees are paid by the other postings, so they are part of the cost
+			default:
+				payments = append(payments, p)
+			}
+		}
+		switch quantity.Sign() {
+		case 1:
+			paid := coin.NewZeroAmount(dc)
+			for _, p := range payments {
+				// prices posted on the transaction date are included
+				amount, err := dc.ConvertAt(p.Quantity, p.Quantity.Commodity, t.Posted.AddDate(0, 0, 1))
+				if err != nil {
+					warn.If(true, "%s: %s, cost basis unknown\n", t.Location(), err)
+					return nil
+				}
+				paid.Sub(paid.Int, amount.Int)
+			}
+			if len(payments) == 0 {
+				if !atRate {
+					return nil
+				}
+				value, err := dc.ConvertAt(quantity, c, t.Posted.AddDate(0, 0, 1))
+				if err != nil {
+					warn.If(true, "%s: %s, cost basis unknown\n", t.Location(), err)
+					return nil
+				}
+				paid = value
+			}
+			cost.Add(cost.Int, paid.Int)
+		case -1:
+			// reduce the cost by the average cost of the disposed quantity
+			if held.Sign() > 0 {
+				reduction := new(big.Int).Mul(cost.Int, quantity.Int)
+				reduction.Quo(reduction, held.Int)
+				cost.Add(cost.Int, reduction)
+			}
+		}
+		held.Add(held.Int, quantity.Int)
+		if held.Sign() <= 0 {
+			held.SetInt64(0)
+			cost.SetInt64(0)
+		}
+	}
+	return cost
+}
+
+// isExpense tells if the account is an expense account, e.g. trading fees.
+func isExpense(a *coin.Account) bool {
+	return a.FullName == "Expenses" || strings.HasPrefix(a.FullName, "Expenses:")
+}
+
+// classes returns the holdings value by commodity class
+func (hs *holdings) classes() (classes allocations) {
+	byClass := map[string]*allocation{}
+	for _, h := range hs.all {
+		if h.value == nil {
+			continue
+		}
+		a := byClass[h.commodity.Class]
+		if a == nil {
+			a = &allocation{class: h.commodity.Class, value: coin.NewZeroAmount(h.value.Commodity)}
+			byClass[a.class] = a
+			classes = append(classes, a)
+		}
+		a.value.Add(a.value.Int, h.value.Int)
+	}
+	sort.Slice(classes, func(i, j int) bool {
+		return classes[i].class < classes[j].class
+	})
+	return classes
+}
+
+// allocation is the value of holdings of a commodity class
+type allocation struct {
+	class string
+	value *coin.Amount
+}
+
+func (a *allocation) label() string {
+	if a.class == "" {
+		return "(none)"
+	}
+	return a.class
+}
+
+type allocations []*allocation
+
+// percentOf returns the value as percentage of the total value,
+// empty if the value or the total is unknown or zero.
+func percentOf(value, total *coin.Amount) string {
+	if value == nil || total.IsZero() {
+		return ""
+	}
+	r := new(big.Rat).SetFrac(value.Int, total.Int)
+	r.Mul(r, big.NewRat(100, 1))
+	return r.FloatString(1) + "%"
+}
+
+// optional formats an amount that may be unknown
+func optional(a *coin.Amount) string {
+	if a == nil {
+		return ""
+	}
+	return a.String()
+}
+
+func (h *holding) priceValue() string {
+	if h.price == nil {
+		return ""
+	}
+	return h.price.Value.String()
+}
+
+func (h *holding) priceCurrency() string {
+	if h.price == nil {
+		return ""
+	}
+	return h.price.Currency.Id
+}
+
+func (h *holding) priceDate() string {
+	if h.price == nil {
+		return ""
+	}
+	return h.price.Time.Format(coin.DateFormat)
+}
+
+func (hs *holdings) rows() (rs rows) {
+	rs = append(rs, []string{"Commodity", "Class", "Quantity", "Price", "Currency", "Date", "Value", "Cost", "Gain", "%"})
+	for _, h := range hs.all {
+		rs = append(rs, []string{
+			h.commodity.Id,
+			h.commodity.Class,
+			h.quantity.String(),
+			h.priceValue(),
+			h.priceCurrency(),
+			h.priceDate(),
+			optional(h.value),
+			optional(h.cost),
+			optional(h.gain()),
+			percentOf(h.value, hs.value),
+		})
+	}
+	total := &holding{value: hs.value, cost: hs.cost}
+	rs = append(rs, []string{
+		"Total", "", "", "", "", "",
+		optional(total.value), optional(total.cost), optional(total.gain()), percentOf(total.value, hs.value),
+	})
+	return rs
+}
+
+func (as allocations) rows(total *coin.Amount) (rs rows) {
+	rs = append(rs, []string{"Class", "Value", "%", "Commodity"})
+	for _, a := range as {
+		rs = append(rs, []string{a.label(), a.value.String(), percentOf(a.value, total), total.Commodity.Id})
+	}
+	return rs
+}
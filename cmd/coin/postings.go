@@ -3,8 +3,10 @@ package main
 import (
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/mkobetic/coin"
 	"github.com/mkobetic/coin/check"
@@ -26,13 +28,21 @@ func trimLocation(loc string) string {
 
 type postings []*coin.Posting
 
-func (ps postings) widths(acctPrefix string) (widths [4]int) {
+func (ps postings) widths(opts *options) (widths [4]int) {
+	var labels int
 	for _, p := range ps {
 		widths[0] = max(widths[0], len(p.Transaction.Description))
-		widths[1] = max(widths[1], len(strings.TrimPrefix(p.Account.FullName, acctPrefix)))
+		widths[1] = max(widths[1], len(strings.TrimPrefix(p.Account.FullName, opts.Prefix())))
 		widths[2] = max(widths[2], p.Quantity.Width(p.Account.Commodity.Decimals))
 		widths[3] = max(widths[3], len(p.Transaction.Other(p).Account.FullName))
+		label, split := opts.other(p)
+		labels = max(labels, len(label))
+		for _, s := range split {
+			widths[2] = max(widths[2], s.Quantity.Width(s.Account.Commodity.Decimals))
+			labels = max(labels, len(opts.accountName(s.Account)))
+		}
 	}
+	widths[3] = max(min(widths[3], opts.MaxAcct()), labels)
 	return widths
 }
 
@@ -46,19 +56,31 @@ func (ps postings) totals(com *coin.Commodity) (ts []*coin.Amount) {
 	return ts
 }
 
+// runningTotals returns the running totals precomputed in opts if present,
+// otherwise computes them in posting order.
+func (ps postings) runningTotals(opts *options) (totals []*coin.Amount, width int) {
+	commodity := opts.commodity
+	if commodity == nil {
+		commodity = ps[0].Account.Commodity
+	}
+	totals = opts.totals
+	if totals == nil {
+		totals = ps.totals(commodity)
+		return totals, totals[len(totals)-1].Width(commodity.Decimals)
+	}
+	for _, t := range totals {
+		width = max(width, t.Width(commodity.Decimals))
+	}
+	return totals, width
+}
+
 func (ps postings) print(f io.Writer, opts *options) {
 	if len(ps) == 0 {
 		return
 	}
-	widths := ps.widths(opts.Prefix())
+	widths := ps.widths(opts)
 	widths[0] = min(widths[0], opts.MaxDesc())
-	widths[3] = min(widths[3], opts.MaxAcct())
-	commodity := opts.commodity
-	if commodity == nil {
-		commodity = ps[0].Account.Commodity
-	}
-	totals := ps.totals(commodity)
-	tWidth := totals[len(totals)-1].Width(commodity.Decimals)
+	totals, tWidth := ps.runningTotals(opts)
 	fmtString := "%s | %*s | %*s | %*a | %*a %s%c\n"
 	if opts.Location() {
 		fmtString = "%s | %*s | %*s | %*a | %*a %s%c| %s\n"
@@ -68,10 +90,11 @@ func (ps postings) print(f io.Writer, opts *options) {
 		if s.BalanceAsserted {
 			reconciled = '*'
 		}
+		other, split := opts.other(s)
 		args := []interface{}{
-			s.Transaction.Posted.Format(coin.DateFormat),
+			opts.date(s).Format(coin.Output.DateFormat),
 			widths[0], s.Transaction.Description,
-			widths[3], coin.ShortenAccountName(strings.TrimPrefix(s.Transaction.Other(s).Account.FullName, opts.Prefix()), opts.MaxAcct()),
+			widths[3], other,
 			widths[2], s.Quantity,
 			tWidth, totals[i],
 			s.Account.CommodityId,
@@ -81,6 +104,14 @@ func (ps postings) print(f io.Writer, opts *options) {
 			args = append(args, trimLocation(s.Transaction.Location()))
 		}
 		fmt.Fprintf(f, fmtString, args...)
+		for _, sp := range split {
+			fmt.Fprintf(f, "%*s | %*s | %*s | %*a %s\n",
+				len(args[0].(string)), "",
+				widths[0], "",
+				widths[3], opts.accountName(sp.Account),
+				widths[2], sp.Quantity,
+				sp.Account.CommodityId)
+		}
 		if opts.showNotes && (len(s.Notes) > 0 || len(s.Transaction.Notes) > 0) {
 			printNotes(f, strings.Repeat(" ", len(args[0].(string)))+" ;", s)
 		}
@@ -91,16 +122,10 @@ func (ps postings) printLong(f io.Writer, opts *options) {
 	if len(ps) == 0 {
 		return
 	}
-	widths := ps.widths(opts.Prefix())
+	widths := ps.widths(opts)
 	widths[0] = min(widths[0], opts.MaxDesc())
 	widths[1] = min(widths[1], opts.MaxAcct())
-	widths[3] = min(widths[3], opts.MaxAcct())
-	commodity := opts.commodity
-	if commodity == nil {
-		commodity = ps[0].Account.Commodity
-	}
-	totals := ps.totals(commodity)
-	tWidth := totals[len(totals)-1].Width(commodity.Decimals)
+	totals, tWidth := ps.runningTotals(opts)
 	fmtString := "%s | %*s | %*s | %*s | %*a | %*a %s%c\n"
 	if opts.Location() {
 		fmtString = "%s | %*s | %*s | %*s | %*a | %*a %s%c| %s\n"
@@ -110,11 +135,12 @@ func (ps postings) printLong(f io.Writer, opts *options) {
 		if s.BalanceAsserted {
 			reconciled = '*'
 		}
+		other, split := opts.other(s)
 		args := []interface{}{
-			s.Transaction.Posted.Format(coin.DateFormat),
+			opts.date(s).Format(coin.Output.DateFormat),
 			widths[0], s.Transaction.Description,
-			widths[1], coin.ShortenAccountName(strings.TrimPrefix(s.Account.FullName, opts.Prefix()), opts.MaxAcct()),
-			widths[3], coin.ShortenAccountName(strings.TrimPrefix(s.Transaction.Other(s).Account.FullName, opts.Prefix()), opts.MaxAcct()),
+			widths[1], opts.accountName(s.Account),
+			widths[3], other,
 			widths[2], s.Quantity,
 			tWidth, totals[i],
 			s.Account.CommodityId,
@@ -124,12 +150,100 @@ func (ps postings) printLong(f io.Writer, opts *options) {
 			args = append(args, trimLocation(s.Transaction.Location()))
 		}
 		fmt.Fprintf(f, fmtString, args...)
+		for _, sp := range split {
+			fmt.Fprintf(f, "%*s | %*s | %*s | %*s | %*a %s\n",
+				len(args[0].(string)), "",
+				widths[0], "",
+				widths[1], "",
+				widths[3], opts.accountName(sp.Account),
+				widths[2], sp.Quantity,
+				sp.Account.CommodityId)
+		}
 		if opts.showNotes && (len(s.Notes) > 0 || len(s.Transaction.Notes) > 0) {
 			printNotes(f, strings.Repeat(" ", len(args[0].(string)))+" ;", s)
 		}
 	}
 }
 
+// sortedPostings sorts postings along with their running totals.
+type sortedPostings struct {
+	postings
+	totals    []*coin.Amount
+	less      func(p1, p2 *coin.Posting, effective bool) bool
+	effective bool // order by effective dates
+}
+
+func (sps *sortedPostings) Len() int { return len(sps.postings) }
+func (sps *sortedPostings) Swap(i, j int) {
+	sps.postings[i], sps.postings[j] = sps.postings[j], sps.postings[i]
+	sps.totals[i], sps.totals[j] = sps.totals[j], sps.totals[i]
+}
+func (sps *sortedPostings) Less(i, j int) bool {
+	return sps.less(sps.postings[i], sps.postings[j], sps.effective)
+}
+
+// postingOrder maps sort keys to posting comparison functions,
+// dates are compared using the effective dates of the postings if effective is set.
+var postingOrder = map[string]func(p1, p2 *coin.Posting, effective bool) bool{
+	"date": func(p1, p2 *coin.Posting, effective bool) bool {
+		return p1.Time(effective).Before(p2.Time(effective))
+	},
+	"amount": func(p1, p2 *coin.Posting, _ bool) bool {
+		return p1.Quantity.IsLessThan(p2.Quantity)
+	},
+	"abs": func(p1, p2 *coin.Posting, _ bool) bool {
+		return p1.Quantity.IsSmaller(p2.Quantity)
+	},
+	"payee": func(p1, p2 *coin.Posting, _ bool) bool {
+		return p1.Transaction.Description < p2.Transaction.Description
+	},
+	"account": func(p1, p2 *coin.Posting, _ bool) bool {
+		if p1.Account != p2.Account {
+			return p1.Account.FullName < p2.Account.FullName
+		}
+		return p1.Transaction.Other(p1).Account.FullName < p2.Transaction.Other(p2).Account.FullName
+	},
+}
+
+// rows returns the postings as rows for structured output formats.
+// Split transactions are rendered the same way as in the text output,
+// expanded split postings are listed in rows following the posting row.
+func (ps postings) rows(opts *options) (rs rows) {
+	rs = append(rs, []string{"Date", "Description", "Account", "Other", "Amount", "Total", "Commodity"})
+	if len(ps) == 0 {
+		return rs
+	}
+	totals, _ := ps.runningTotals(opts)
+	// use full account names in structured output
+	full := *opts
+	full.prefix, full.maxAcct = "", math.MaxInt
+	for i, s := range ps {
+		date := opts.date(s).Format(coin.DateFormat)
+		other, split := full.other(s)
+		rs = append(rs, []string{
+			date,
+			s.Transaction.Description,
+			s.Account.FullName,
+			other,
+			s.Quantity.String(),
+			totals[i].String(),
+			s.Account.CommodityId,
+		})
+		for _, sp := range split {
+			rs = append(rs, []string{
+				date,
+				s.Transaction.Description,
+				s.Account.FullName,
+				sp.Account.FullName,
+				sp.Quantity.String(),
+				"",
+				sp.Account.CommodityId,
+			})
+		}
+	}
+	return rs
+}
+
 func printNotes(w io.Writer, prefix string, p *coin.Posting) {
 	for _, line := range append(p.Notes, p.Transaction.Notes...) {
 		fmt.Fprintln(w, prefix, line)
@@ -142,6 +256,50 @@ type options struct {
 	maxDesc, maxAcct int
 	commodity        *coin.Commodity
 	showNotes        bool
+	totals           []*coin.Amount // precomputed running totals (optional)
+	split            string         // how to show transactions with multiple counterparts
+	effective        bool           // show effective dates of postings
+}
+
+// Split transaction rendering modes
+const (
+	splitExpand = "expand" // show (split) as the counterpart and list the counterparts underneath
+	splitList   = "list"   // list all counterparts in the counterpart column
+	splitFirst  = "first"  // show only the first counterpart
+)
+
+// date returns the date of the posting to show
+func (o *options) date(s *coin.Posting) time.Time {
+	return s.Date(o != nil && o.effective)
+}
+
+func (o *options) Split() string {
+	if o == nil || o.split == "" {
+		return splitExpand
+	}
+	return o.split
+}
+
+// accountName returns the account name shortened for display
+func (o *options) accountName(a *coin.Account) string {
+	return coin.ShortenAccountName(strings.TrimPrefix(a.FullName, o.Prefix()), o.MaxAcct())
+}
+
+// other returns the counterpart label of the posting
+// and the counterpart postings to list underneath it (if any).
+func (o *options) other(s *coin.Posting) (string, []*coin.Posting) {
+	others := s.Transaction.Others(s)
+	if len(others) == 1 || o.Split() == splitFirst {
+		return o.accountName(others[0].Account), nil
+	}
+	if o.Split() == splitList {
+		var names []string
+		for _, p := range others {
+			names = append(names, o.accountName(p.Account))
+		}
+		return strings.Join(names, ", "), nil
+	}
+	return "(split)", others
 }
 
 func (o *options) MaxDesc() int {
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/mkobetic/coin"
+	"github.com/mkobetic/coin/check"
+	"github.com/mkobetic/coin/check/warn"
+)
+
+func init() {
+	(&cmdPrices{}).newCommand("prices", "pr")
+}
+
+type cmdPrices struct {
+	flagsWithUsage
+	gaps   int
+	jumps  float64
+	thin   coin.Date
+	keep   string
+	write  bool
+	output string
+}
+
+func (*cmdPrices) newCommand(names ...string) command {
+	var cmd cmdPrices
+	cmd.FlagSet = newCommand(&cmd, names...)
+	setUsage(cmd.FlagSet, `(prices|pr) [flags] [commodity]
+
+Price database maintenance for all commodities or the specified commodity.
+Reports gaps between prices longer than the given number of days (-gaps),
+and changes between consecutive prices above the given percentage (-jumps).
+Thins the price history (-thin) keeping only the last price of each week or month
+(-keep) before the given date, and removing duplicate prices for the same date.
+The thinned prices are printed or written back (-w) to the price files grouped by year.`)
+	cmd.IntVar(&cmd.gaps, "gaps", 0, "report gaps between prices longer than this number of days")
+	cmd.Float64Var(&cmd.jumps, "jumps", 0, "report price changes above this percentage")
+	cmd.Var(&cmd.thin, "thin", "thin prices older than this date")
+	cmd.StringVar(&cmd.keep, "keep", "month", "keep last price of each week or month when thinning")
+	cmd.BoolVar(&cmd.write, "w", false, "write thinned prices back to the price files (YEAR"+coin.PricesExtension+")")
+	outputFlag(cmd.FlagSet, &cmd.output)
+	return &cmd
+}
+
+func (cmd *cmdPrices) init() {
+	// events are not applied, the prices may be written back
+	coin.LoadPrices()
+	coin.ResolvePrices()
+}
+
+func (cmd *cmdPrices) execute(f io.Writer) {
+	check.If(cmd.gaps > 0 || cmd.jumps > 0 || !cmd.thin.IsZero(),
+		"specify -gaps, -jumps or -thin\n")
+	var commodity *coin.Commodity
+	if cmd.NArg() > 0 {
+		commodity = coin.MustFindCommodity(cmd.Arg(0), "prices")
+	}
+	var pairs []priceList
+	coin.CommoditiesDo(func(c *coin.Commodity) {
+		if commodity != nil && c != commodity {
+			return
+		}
+		for _, cur := range sortedCurrencies(c) {
+			pairs = append(pairs, newPriceList(c.Prices[cur]))
+		}
+	})
+	switch {
+	case cmd.gaps > 0:
+		cmd.gapRows(pairs).write(f, cmd.output)
+	case cmd.jumps > 0:
+		cmd.jumpRows(pairs).write(f, cmd.output)
+	default:
+		cmd.thinPrices(f, pairs, commodity)
+	}
+}
+
+// priceList is the list of prices of a commodity in a currency ordered by date
+type priceList []*coin.Price
+
+// newPriceList returns the prices ordered by date,
+// prices are kept by commodities ordered from the latest
+func newPriceList(prices []*coin.Price) (ps priceList) {
+	for i := len(prices) - 1; i >= 0; i-- {
+		ps = append(ps, prices[i])
+	}
+	return ps
+}
+
+func (ps priceList) gaps(days int) (gaps [][2]*coin.Price) {
+	for i := 1; i < len(ps); i++ {
+		if ps[i].Time.Sub(ps[i-1].Time) > time.Duration(days)*24*time.Hour {
+			gaps = append(gaps, [2]*coin.Price{ps[i-1], ps[i]})
+		}
+	}
+	return gaps
+}
+
+// priceChange returns the percentage change from price p1 to p2,
+// nil if p1 is zero.
+func priceChange(p1, p2 *coin.Price) *big.Rat {
+	r1 := p1.ExactRate()
+	if r1.Sign() == 0 {
+		return nil
+	}
+	r := new(big.Rat).Sub(p2.ExactRate(), r1)
+	r.Quo(r, r1)
+	return r.Mul(r, big.NewRat(100, 1))
+}
+
+func (ps priceList) jumps(percent float64) (jumps [][2]*coin.Price) {
+	threshold := new(big.Rat).SetFloat64(percent)
+	for i := 1; i < len(ps); i++ {
+		if c := priceChange(ps[i-1], ps[i]); c != nil && new(big.Rat).Abs(c).Cmp(threshold) > 0 {
+			jumps = append(jumps, [2]*coin.Price{ps[i-1], ps[i]})
+		}
+	}
+	return jumps
+}
+
+// thin returns the prices without duplicates for the same date,
+// keeping only the last price of each period for prices before the date.
+// Of the duplicates the price loaded last is kept.
+func (ps priceList) thin(before time.Time, by *reducer) (thinned priceList) {
+	var unique priceList
+	for i, p := range ps {
+		// prices loaded later come first among prices for the same date
+		if i > 0 && ps[i-1].Time.Equal(p.Time) {
+			continue
+		}
+		unique = append(unique, p)
+	}
+	for i, p := range unique {
+		if i+1 < len(unique) {
+			next := unique[i+1]
+			if next.Time.Before(before) && by.reduce(p.Time).Equal(by.reduce(next.Time)) {
+				continue // not the last price of the period
+			}
+		}
+		thinned = append(thinned, p)
+	}
+	return thinned
+}
+
+func (cmd *cmdPrices) gapRows(pairs []priceList) (rs rows) {
+	rs = append(rs, []string{"Commodity", "Currency", "From", "To", "Days"})
+	for _, ps := range pairs {
+		for _, gap := range ps.gaps(cmd.gaps) {
+			rs = append(rs, []string{
+				gap[0].Commodity.Id,
+				gap[0].Currency.Id,
+				gap[0].Time.Format(coin.DateFormat),
+				gap[1].Time.Format(coin.DateFormat),
+				strconv.Itoa(int(gap[1].Time.Sub(gap[0].Time).Hours() / 24)),
+			})
+		}
+	}
+	return rs
+}
+
+func (cmd *cmdPrices) jumpRows(pairs []priceList) (rs rows) {
+	rs = append(rs, []string{"Commodity", "Currency", "Date", "Previous", "Price", "%"})
+	for _, ps := range pairs {
+		for _, jump := range ps.jumps(cmd.jumps) {
+			rs = append(rs, []string{
+				jump[1].Commodity.Id,
+				jump[1].Currency.Id,
+				jump[1].Time.Format(coin.DateFormat),
+				jump[0].RateString(),
+				jump[1].RateString(),
+				priceChange(jump[0], jump[1]).FloatString(1) + "%",
+			})
+		}
+	}
+	return rs
+}
+
+// thinPrices thins the prices of the pairs, when writing back the prices
+// of the commodities other than the selected one (if any) are kept as they are.
+func (cmd *cmdPrices) thinPrices(f io.Writer, pairs []priceList, commodity *coin.Commodity) {
+	by := map[string]*reducer{"week": &week, "month": &month}[cmd.keep]
+	check.If(by != nil, "invalid -keep %s, use week or month\n", cmd.keep)
+	var all []*coin.Price
+	for _, ps := range pairs {
+		all = append(all, ps.thin(cmd.thin.Time, by)...)
+	}
+	if cmd.write && commodity != nil {
+		for _, p := range coin.Prices {
+			if p.Commodity != commodity {
+				all = append(all, p)
+			}
+		}
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].Time.Before(all[j].Time)
+	})
+	if !cmd.write {
+		for _, p := range all {
+			err := p.Write(f, true)
+			check.NoError(err, "writing price %s", p.Location())
+		}
+		return
+	}
+	writePricesByYear(all)
+}
+
+// writePricesByYear replaces the price files in the database with files
+// containing the prices of each year. Any other lines of the existing files
+// (comments, directives) are kept at the top of the files.
+func writePricesByYear(prices []*coin.Price) {
+	_, err := os.Stat(coin.PricesFile)
+	check.If(os.IsNotExist(err), "cannot write prices by year, prices are loaded from %s\n", coin.PricesFile)
+	old, err := filepath.Glob(filepath.Join(coin.DB, "*"+coin.PricesExtension))
+	check.NoError(err, "listing price files")
+	heads := map[string][]byte{}
+	for _, fn := range old {
+		heads[fn] = nonPriceLines(fn)
+	}
+	byYear := map[int][]*coin.Price{}
+	var years []int
+	for _, p := range prices {
+		y := p.Time.Year()
+		if byYear[y] == nil {
+			years = append(years, y)
+		}
+		byYear[y] = append(byYear[y], p)
+	}
+	written := map[string]bool{}
+	for _, y := range years {
+		fn := filepath.Join(coin.DB, strconv.Itoa(y)+coin.PricesExtension)
+		writePriceFile(fn, heads[fn], byYear[y])
+		written[fn] = true
+	}
+	for _, fn := range old {
+		switch {
+		case written[fn]:
+		case len(heads[fn]) > 0:
+			warn.If(true, "%s has no prices left, keeping its other lines\n", fn)
+			writePriceFile(fn, heads[fn], nil)
+		default:
+			check.NoError(os.Remove(fn), "removing %s", fn)
+		}
+	}
+}
+
+// nonPriceLines returns the lines of the price file that aren't prices or blank.
+func nonPriceLines(fn string) (lines []byte) {
+	content, err := os.ReadFile(fn)
+	check.NoError(err, "reading %s", fn)
+	for _, line := range bytes.SplitAfter(content, []byte("\n")) {
+		if trimmed := bytes.TrimSpace(line); len(trimmed) == 0 || bytes.HasPrefix(trimmed, []byte("P ")) {
+			continue
+		}
+		lines = append(lines, bytes.TrimRight(line, "\r\n")...)
+		lines = append(lines, '\n')
+	}
+	return lines
+}
+
+// appendPrices appends the prices to the price files of their year (YEAR.prices),
+// or to prices.coin if the prices are loaded from it.
+func appendPrices(prices []*coin.Price) {
+	_, err := os.Stat(coin.PricesFile)
+	byYear := os.IsNotExist(err)
+	byFile := map[string][]*coin.Price{}
+	var files []string
+	for _, p := range prices {
+		fn := coin.PricesFile
+		if byYear {
+			fn = filepath.Join(coin.DB, strconv.Itoa(p.Time.Year())+coin.PricesExtension)
+		}
+		if byFile[fn] == nil {
+			files = append(files, fn)
+		}
+		byFile[fn] = append(byFile[fn], p)
+	}
+	for _, fn := range files {
+		head, err := os.ReadFile(fn)
+		if !os.IsNotExist(err) {
+			check.NoError(err, "reading %s", fn)
+		}
+		if len(head) > 0 && head[len(head)-1] != '\n' {
+			head = append(head, '\n')
+		}
+		writePriceFile(fn, head, byFile[fn])
+	}
+}
+
+// writePriceFile writes the head and the prices into a temporary file first
+// and then renames it to the target file name, so that the file is replaced atomically.
+func writePriceFile(fn string, head []byte, prices []*coin.Price) {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(fn); err == nil {
+		mode = info.Mode()
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(fn), filepath.Base(fn)+".*")
+	check.NoError(err, "creating %s", fn)
+	_, err = tmp.Write(head)
+	check.NoError(err, "writing %s", tmp.Name())
+	for _, p := range prices {
+		err = p.Write(tmp, true)
+		check.NoError(err, "writing %s", tmp.Name())
+	}
+	check.NoError(tmp.Chmod(mode), "changing mode of %s", tmp.Name())
+	check.NoError(tmp.Close(), "closing %s", tmp.Name())
+	check.NoError(os.Rename(tmp.Name(), fn), "renaming %s", tmp.Name())
+	fmt.Fprintf(os.Stderr, "wrote %d prices to %s\n", len(prices), fn)
+}
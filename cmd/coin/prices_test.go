@@ -0,0 +1,113 @@
+package main
+
+import (
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mkobetic/coin"
+	"github.com/mkobetic/coin/assert"
+)
+
+// useDB points the coin database at a temporary directory with the files,
+// the database and the loaded commodities and prices are restored when the test ends.
+func useDB(t *testing.T, files map[string]string) string {
+	db := t.TempDir()
+	for name, content := range files {
+		assert.NoError(t, os.WriteFile(filepath.Join(db, name), []byte(content), 0644))
+	}
+	dbVars := []*string{&coin.DB, &coin.CommoditiesFile, &coin.PricesFile}
+	saved := []string{coin.DB, coin.CommoditiesFile, coin.PricesFile}
+	commodities, prices := coin.Commodities, coin.Prices
+	t.Cleanup(func() {
+		for i, v := range dbVars {
+			*v = saved[i]
+		}
+		coin.Commodities, coin.Prices = commodities, prices
+	})
+	coin.DB = db
+	coin.CommoditiesFile = filepath.Join(db, coin.CommoditiesFilename)
+	coin.PricesFile = filepath.Join(db, coin.PricesFilename)
+	coin.Commodities, coin.Prices = map[string]*coin.Commodity{}, nil
+	return db
+}
+
+func Test_ThinPricesWriteKeepsOtherCommodities(t *testing.T) {
+	db := useDB(t, map[string]string{
+		coin.CommoditiesFilename: `commodity CAD
+  format 1.00 CAD
+
+commodity VGRO
+  format 1.00 VGRO
+
+commodity XIC
+  format 1.00 XIC
+`,
+		"2010.prices": `P 2010/01/04 VGRO 10.00 CAD
+P 2010/01/05 VGRO 10.10 CAD
+P 2010/01/06 VGRO 10.20 CAD
+P 2010/01/05 XIC 20.00 CAD
+P 2010/01/05 XIC 20.05 CAD
+`,
+		"2011.prices": "P 2011/01/05 XIC 21.00 CAD\n",
+	})
+	cmd := (&cmdPrices{}).newCommand("prices").(*cmdPrices)
+	assert.NoError(t, cmd.Parse([]string{"-thin", "2010/06/01", "-w", "VGRO"}))
+	cmd.init()
+	cmd.execute(io.Discard)
+
+	content, err := os.ReadFile(filepath.Join(db, "2010.prices"))
+	assert.NoError(t, err)
+	// VGRO is thinned, XIC prices including the duplicate are kept
+	assert.Equal(t, string(content), `P 2010/01/05 XIC 20.00 CAD
+P 2010/01/05 XIC 20.05 CAD
+P 2010/01/06 VGRO 10.20 CAD
+`)
+	content, err = os.ReadFile(filepath.Join(db, "2011.prices"))
+	assert.NoError(t, err)
+	assert.Equal(t, string(content), "P 2011/01/05 XIC 21.00 CAD\n")
+}
+
+func Test_ThinPricesWriteKeepsOtherLines(t *testing.T) {
+	db := useDB(t, map[string]string{
+		coin.CommoditiesFilename: `commodity CAD
+  format 1.00 CAD
+
+commodity VGRO
+  format 1.00 VGRO
+`,
+		"2009.prices": `; VGRO before the split
+P 2009/01/05 VGRO 30.00 CAD
+`,
+		"2010.prices": `split 2010/01/04 VGRO 3:1
+P 2010/01/05 VGRO 10.00 CAD
+P 2010/01/06 VGRO 10.20 CAD
+`,
+	})
+	cmd := (&cmdPrices{}).newCommand("prices").(*cmdPrices)
+	assert.NoError(t, cmd.Parse([]string{"-thin", "2011/01/01", "-keep", "month", "-w"}))
+	cmd.init()
+	cmd.execute(io.Discard)
+
+	content, err := os.ReadFile(filepath.Join(db, "2009.prices"))
+	assert.NoError(t, err)
+	assert.Equal(t, string(content), `; VGRO before the split
+P 2009/01/05 VGRO 30.00 CAD
+`)
+	content, err = os.ReadFile(filepath.Join(db, "2010.prices"))
+	assert.NoError(t, err)
+	assert.Equal(t, string(content), `split 2010/01/04 VGRO 3:1
+P 2010/01/06 VGRO 10.20 CAD
+`)
+}
+
+func Test_PriceChangeWithoutRate(t *testing.T) {
+	cad := &coin.Commodity{Id: "CAD", Decimals: 2}
+	p1 := &coin.Price{Currency: cad, Value: coin.NewAmount(big.NewInt(1000), cad)}
+	p2 := &coin.Price{Currency: cad, Value: coin.NewAmount(big.NewInt(1250), cad)}
+	assert.Equal(t, priceChange(p1, p2).FloatString(2), "25.00")
+	p1.Value = coin.NewZeroAmount(cad)
+	assert.Equal(t, priceChange(p1, p2) == nil, true)
+}
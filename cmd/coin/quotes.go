@@ -0,0 +1,407 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mkobetic/coin"
+	finance "github.com/piquette/finance-go"
+	ychart "github.com/piquette/finance-go/chart"
+	"github.com/piquette/finance-go/datetime"
+	"github.com/piquette/finance-go/forex"
+	yahoo "github.com/piquette/finance-go/quote"
+)
+
+// QuoteProvider fetches current or historical commodity prices.
+// Commodities select their provider with the quote directive:
+//
+//	quote yahoo
+//	quote json URL PATH [CURRENCY]
+//	quote file PATH
+//
+// Commodities without the quote directive use yahoo.
+type QuoteProvider interface {
+	// Quote returns the current price of the commodity
+	Quote(c *coin.Commodity) (*coin.Price, error)
+	// History returns the daily prices of the commodity from the date until today ordered by date
+	History(c *coin.Commodity, from time.Time) ([]*coin.Price, error)
+}
+
+// quoteProviders caches providers by their quote directive,
+// so that files are read only once.
+var quoteProviders = map[string]QuoteProvider{}
+
+// quoteProvider returns the provider specified by the commodity quote directive
+func quoteProvider(c *coin.Commodity) (QuoteProvider, error) {
+	spec := c.Quote
+	if spec == "" {
+		spec = "yahoo"
+	}
+	if qp := quoteProviders[spec]; qp != nil {
+		return qp, nil
+	}
+	var qp QuoteProvider
+	args := strings.Fields(spec)
+	switch {
+	case args[0] == "yahoo" && len(args) == 1:
+		qp = yahooProvider{}
+	case args[0] == "json" && (len(args) == 3 || len(args) == 4):
+		jp := &jsonProvider{url: args[1], path: strings.Split(args[2], ".")}
+		if len(args) == 4 {
+			jp.currency = args[3]
+		}
+		qp = jp
+	case args[0] == "file" && len(args) == 2:
+		qp = &fileProvider{file: args[1]}
+	default:
+		return nil, fmt.Errorf("invalid quote directive: %s", spec)
+	}
+	quoteProviders[spec] = qp
+	return qp, nil
+}
+
+// quoteSymbol returns the symbol of the commodity used for quotes
+func quoteSymbol(c *coin.Commodity) string {
+	if c.Symbol != "" {
+		return c.Symbol
+	}
+	return c.Id
+}
+
+// newQuote returns the price of commodity c, value is parsed as a decimal number
+func newQuote(c *coin.Commodity, date time.Time, value string, currencyId string) (*coin.Price, error) {
+	cur := coin.Commodities[currencyId]
+	if cur == nil {
+		return nil, fmt.Errorf("no commodity for %s", currencyId)
+	}
+	r, ok := new(big.Rat).SetString(value)
+	if !ok {
+		return nil, fmt.Errorf("invalid price %s", value)
+	}
+	return coin.NewPrice(c, cur, date, r), nil
+}
+
+// today returns the current date in the same form as the parsed dates
+func today() time.Time {
+	return time.Date(coin.Year, time.Month(coin.Month), coin.Day, 12, 0, 0, 0, time.UTC)
+}
+
+// yahooProvider fetches quotes from Yahoo Finance, commodities without
+// a symbol are quoted as currency pairs with the default commodity.
+type yahooProvider struct{}
+
+func (yahooProvider) Quote(c *coin.Commodity) (*coin.Price, error) {
+	var q *finance.Quote
+	if c.Symbol != "" {
+		var err error
+		if q, err = yahoo.Get(c.Symbol); err != nil {
+			return nil, err
+		}
+	} else {
+		fx, err := forex.Get(c.Id + coin.DefaultCommodityId + "=X")
+		if err != nil {
+			return nil, err
+		}
+		q = &fx.Quote
+	}
+	if q == nil {
+		return nil, fmt.Errorf("no quote for %s", quoteSymbol(c))
+	}
+	return newQuote(c, today(), strconv.FormatFloat(q.RegularMarketPrice, 'f', -1, 64), q.CurrencyID)
+}
+
+func (yahooProvider) History(c *coin.Commodity, from time.Time) (prices []*coin.Price, err error) {
+	symbol := c.Symbol
+	if symbol == "" {
+		symbol = c.Id + coin.DefaultCommodityId + "=X"
+	}
+	end := time.Now()
+	bars := ychart.Get(&ychart.Params{
+		Symbol:   symbol,
+		Start:    datetime.New(&from),
+		End:      datetime.New(&end),
+		Interval: datetime.OneDay,
+	})
+	for bars.Next() {
+		bar := bars.Bar()
+		y, m, d := time.Unix(int64(bar.Timestamp), 0).UTC().Date()
+		date := time.Date(y, m, d, 12, 0, 0, 0, time.UTC)
+		p, err := newQuote(c, date, bar.Close.String(), bars.Meta().Currency)
+		if err != nil {
+			return nil, err
+		}
+		prices = append(prices, p)
+	}
+	return prices, bars.Err()
+}
+
+// jsonProvider fetches quotes from a URL returning JSON. The URL can include
+// {symbol}, {commodity}, {currency} and {date} (YYYY-MM-DD) placeholders, the price is found
+// following the dot separated path of object keys or array indexes (e.g. data.0.price).
+// The price is in the default commodity unless the currency is specified.
+// Historical prices are fetched day by day and require the {date} placeholder,
+// days without a price (not found) are skipped, other error responses fail.
+type jsonProvider struct {
+	url      string
+	path     []string
+	currency string
+}
+
+func (jp *jsonProvider) currencyId() string {
+	if jp.currency != "" {
+		return jp.currency
+	}
+	return coin.DefaultCommodityId
+}
+
+func (jp *jsonProvider) Quote(c *coin.Commodity) (*coin.Price, error) {
+	return jp.quote(c, today())
+}
+
+func (jp *jsonProvider) History(c *coin.Commodity, from time.Time) (prices []*coin.Price, err error) {
+	if !strings.Contains(jp.url, "{date}") {
+		return nil, fmt.Errorf("historical quotes require {date} in %s", jp.url)
+	}
+	for date := from; !date.After(today()); date = date.AddDate(0, 0, 1) {
+		p, err := jp.quote(c, date)
+		var se *statusError
+		if errors.As(err, &se) && se.code == http.StatusNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		prices = append(prices, p)
+	}
+	return prices, nil
+}
+
+// statusError is returned for responses that are not OK
+type statusError struct {
+	url    string
+	code   int
+	status string
+}
+
+func (e *statusError) Error() string { return e.url + ": " + e.status }
+
+// quote returns the price of the commodity on the date
+func (jp *jsonProvider) quote(c *coin.Commodity, date time.Time) (*coin.Price, error) {
+	url := strings.NewReplacer(
+		"{symbol}", quoteSymbol(c),
+		"{commodity}", c.Id,
+		"{currency}", jp.currencyId(),
+		"{date}", date.Format("2006-01-02"),
+	).Replace(jp.url)
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &statusError{url: url, code: resp.StatusCode, status: resp.Status}
+	}
+	dec := json.NewDecoder(resp.Body)
+	dec.UseNumber()
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return nil, fmt.Errorf("%s: %s", url, err)
+	}
+	for _, key := range jp.path {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			value = v[key]
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, fmt.Errorf("%s: invalid index %s", url, key)
+			}
+			value = v[i]
+		default:
+			value = nil
+		}
+		if value == nil {
+			return nil, fmt.Errorf("%s: missing %s", url, strings.Join(jp.path, "."))
+		}
+	}
+	switch v := value.(type) {
+	case json.Number:
+		return newQuote(c, date, v.String(), jp.currencyId())
+	case string:
+		return newQuote(c, date, v, jp.currencyId())
+	}
+	return nil, fmt.Errorf("%s: %s is not a number", url, strings.Join(jp.path, "."))
+}
+
+// fileProvider reads quotes from a local file, relative paths are relative to COINDB.
+// Files with .xml extension are ECB style exchange rate files with the rates
+// of currencies in EUR, e.g.
+//
+//	<Cube time="2024-01-05"><Cube currency="USD" rate="1.0921"/></Cube>
+//
+// other files are CSV files with date, symbol, price and currency columns, e.g.
+//
+//	2024/01/05,VGRO,30.52,CAD
+//
+// The current quote is the latest quote of the commodity symbol in the file.
+type fileProvider struct {
+	file   string
+	quotes map[string][]*fileQuote // by symbol
+}
+
+type fileQuote struct {
+	date     time.Time
+	price    *big.Rat
+	currency string
+}
+
+func (fp *fileProvider) Quote(c *coin.Commodity) (*coin.Price, error) {
+	prices, err := fp.History(c, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	if len(prices) == 0 {
+		return nil, fmt.Errorf("no quote for %s in %s", quoteSymbol(c), fp.file)
+	}
+	return prices[len(prices)-1], nil
+}
+
+func (fp *fileProvider) History(c *coin.Commodity, from time.Time) (prices []*coin.Price, err error) {
+	if fp.quotes == nil {
+		if err := fp.load(); err != nil {
+			return nil, err
+		}
+	}
+	for _, q := range fp.quotes[quoteSymbol(c)] {
+		if q.date.Before(from) {
+			continue
+		}
+		cur := coin.Commodities[q.currency]
+		if cur == nil {
+			return nil, fmt.Errorf("no commodity for %s", q.currency)
+		}
+		prices = append(prices, coin.NewPrice(c, cur, q.date, q.price))
+	}
+	sort.SliceStable(prices, func(i, j int) bool {
+		return prices[i].Time.Before(prices[j].Time)
+	})
+	return prices, nil
+}
+
+func (fp *fileProvider) load() error {
+	fn := fp.file
+	if !filepath.IsAbs(fn) {
+		fn = filepath.Join(coin.DB, fn)
+	}
+	f, err := os.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fp.quotes = map[string][]*fileQuote{}
+	if strings.EqualFold(filepath.Ext(fn), ".xml") {
+		err = fp.loadECB(f)
+	} else {
+		err = fp.loadCSV(f)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %s", fn, err)
+	}
+	return nil
+}
+
+func (fp *fileProvider) add(symbol string, q *fileQuote) {
+	fp.quotes[symbol] = append(fp.quotes[symbol], q)
+}
+
+func (fp *fileProvider) loadCSV(r io.Reader) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = 4
+	cr.TrimLeadingSpace = true
+	for line := 1; ; line++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		date, err := parseQuoteDate(record[0])
+		if err != nil {
+			if line == 1 {
+				continue // header
+			}
+			return fmt.Errorf("line %d: %s", line, err)
+		}
+		price, ok := new(big.Rat).SetString(record[2])
+		if !ok {
+			return fmt.Errorf("line %d: invalid price %s", line, record[2])
+		}
+		fp.add(record[1], &fileQuote{date: date, price: price, currency: record[3]})
+	}
+}
+
+// ecbCube is the nested Cube element structure of ECB rate files,
+// the outer Cube elements are ignored.
+type ecbCube struct {
+	Time     string    `xml:"time,attr"`
+	Currency string    `xml:"currency,attr"`
+	Rate     string    `xml:"rate,attr"`
+	Cubes    []ecbCube `xml:"Cube"`
+}
+
+func (fp *fileProvider) loadECB(r io.Reader) error {
+	var envelope struct {
+		Cubes []ecbCube `xml:"Cube"`
+	}
+	if err := xml.NewDecoder(r).Decode(&envelope); err != nil {
+		return err
+	}
+	var walk func(cubes []ecbCube, date time.Time) error
+	walk = func(cubes []ecbCube, date time.Time) error {
+		for _, cube := range cubes {
+			d := date
+			if cube.Time != "" {
+				var err error
+				if d, err = parseQuoteDate(cube.Time); err != nil {
+					return err
+				}
+			}
+			if cube.Currency != "" {
+				rate, ok := new(big.Rat).SetString(cube.Rate)
+				if !ok || rate.Sign() == 0 {
+					return fmt.Errorf("invalid %s rate %s", cube.Currency, cube.Rate)
+				}
+				// the rate is the price of EUR in the currency
+				fp.add(cube.Currency, &fileQuote{date: d, price: rate.Inv(rate), currency: "EUR"})
+			}
+			if err := walk(cube.Cubes, d); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk(envelope.Cubes, time.Time{})
+}
+
+// parseQuoteDate parses dates as YYYY/MM/DD or YYYY-MM-DD
+// in the same form as the parsed ledger dates.
+func parseQuoteDate(s string) (time.Time, error) {
+	d, err := time.Parse(coin.DateFormat, s)
+	if err != nil {
+		d, err = time.Parse("2006-01-02", s)
+	}
+	return d.Add(12 * time.Hour), err
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mkobetic/coin"
+	"github.com/mkobetic/coin/assert"
+)
+
+// useQuoteGlobals restores the commodities, the cached quote providers
+// and today's date when the test ends.
+func useQuoteGlobals(t *testing.T) {
+	commodities, providers := coin.Commodities, quoteProviders
+	y, m, d := coin.Year, coin.Month, coin.Day
+	t.Cleanup(func() {
+		coin.Commodities, quoteProviders = commodities, providers
+		coin.Year, coin.Month, coin.Day = y, m, d
+	})
+	coin.Commodities = maps.Clone(commodities)
+	quoteProviders = map[string]QuoteProvider{}
+}
+
+func Test_JSONProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/VGRO.TO":
+			fmt.Fprint(w, `{"data": [{"symbol": "VGRO.TO", "price": 30.52}]}`)
+		case "/VFV":
+			fmt.Fprint(w, `{"data": [{"symbol": "VFV", "price": "110.25"}]}`)
+		case "/VFV/2024-01-04":
+			fmt.Fprint(w, `{"data": [{"symbol": "VFV", "price": 109.80}]}`)
+		case "/ERR":
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	useQuoteGlobals(t)
+
+	cad := &coin.Commodity{Id: "CAD", Decimals: 2}
+	coin.Commodities["CAD"] = cad
+	spec := "json " + server.URL + "/{symbol} data.0.price CAD"
+
+	vgro := &coin.Commodity{Id: "VGRO", Symbol: "VGRO.TO", Quote: spec}
+	qp, err := quoteProvider(vgro)
+	assert.NoError(t, err)
+	p, err := qp.Quote(vgro)
+	assert.NoError(t, err)
+	assert.Equal(t, p.Commodity, vgro)
+	assert.Equal(t, p.Currency, cad)
+	assert.Equal(t, p.Value.String(), "30.52")
+
+	vfv := &coin.Commodity{Id: "VFV", Quote: spec}
+	p, err = qp.Quote(vfv)
+	assert.NoError(t, err)
+	assert.Equal(t, p.Value.String(), "110.25")
+
+	_, err = qp.Quote(&coin.Commodity{Id: "XYZ", Quote: spec})
+	assert.True(t, err != nil && strings.Contains(err.Error(), "404"), "missing quote should fail with the status")
+
+	_, err = qp.Quote(&coin.Commodity{Id: "ERR", Quote: spec})
+	assert.True(t, err != nil && strings.Contains(err.Error(), "503"), "error response should fail with the status")
+
+	_, err = quoteProvider(&coin.Commodity{Id: "XYZ", Quote: "json " + server.URL})
+	assert.True(t, err != nil, "invalid quote directive should fail")
+
+	_, err = qp.History(vfv, coin.MustParseDate("2024/01/03"))
+	assert.True(t, err != nil, "history without {date} should fail")
+
+	vfv.Quote = "json " + server.URL + "/{symbol}/{date} data.0.price CAD"
+	qp, err = quoteProvider(vfv)
+	assert.NoError(t, err)
+	from := coin.MustParseDate("2024/01/03")
+	coin.Year, coin.Month, coin.Day = 2024, 1, 5
+	ps, err := qp.History(vfv, from)
+	assert.NoError(t, err)
+	assert.Equal(t, len(ps), 1)
+	assert.Equal(t, ps[0].Time.Format(coin.DateFormat), "2024/01/04")
+	assert.Equal(t, ps[0].Value.String(), "109.80")
+}
@@ -21,16 +21,25 @@ type cmdRegister struct {
 	verbose           bool
 	recurse           bool
 	begin, end        coin.Date
+	effective         bool
 	weekly, monthly   bool
 	quarterly, yearly bool
 	top               int
 	cumulative        bool
+	pivot             string
+	stats             bool
+	average           int
 	maxLabelWidth     int
 	location          bool
 	output            string
 	showNotes         bool
 	payee             string
 	tag               string
+	sortBy            string
+	limit             int
+	reverse           bool
+	split             string
+	kind              string
 }
 
 func (*cmdRegister) newCommand(names ...string) command {
@@ -44,6 +53,7 @@ Lists or aggregate postings from the specified account.`)
 	// filtering options
 	cmd.Var(&cmd.begin, "b", "begin register from this date")
 	cmd.Var(&cmd.end, "e", "end register on this date")
+	cmd.BoolVar(&cmd.effective, "effective", false, "use effective dates of postings (DATE=DATE2, [=DATE])")
 	cmd.StringVar(&cmd.payee, "p", "", "use only postings matching the payee ([!]regex)")
 	cmd.StringVar(&cmd.tag, "t", "", "use only postings matching the tag[:value] ([!]regex)")
 	// aggregation options
@@ -53,27 +63,40 @@ Lists or aggregate postings from the specified account.`)
 	cmd.BoolVar(&cmd.yearly, "y", false, "aggregate postings by year")
 	cmd.IntVar(&cmd.top, "g", 5, "include this many largest subaccounts in aggregate results")
 	cmd.BoolVar(&cmd.cumulative, "c", false, "aggregate cumulatively across time")
+	cmd.StringVar(&cmd.pivot, "by", "account", "aggregate postings by: account, payee, tag:NAME (value of tag NAME)")
+	cmd.BoolVar(&cmd.stats, "s", false, "append mean, median, min, max and trend rows to aggregate results")
+	cmd.IntVar(&cmd.average, "a", 0, "add a moving average column over this many periods to aggregate results")
 	// output options
 	cmd.IntVar(&cmd.maxLabelWidth, "l", 12, "maximum width of a column label")
 	cmd.BoolVar(&cmd.location, "f", false, "include file location on postings in non-aggregated results")
-	cmd.StringVar(&cmd.output, "o", "text", "output format for aggregated results: text, json, csv")
+	outputFlag(cmd.FlagSet, &cmd.output, outChart, outSVG, outHTML+" (aggregated)")
+	cmd.StringVar(&cmd.kind, "k", "", "chart kind: bar, stacked, spark (default bar) or svg and html chart kind: line, area (default line)")
+	cmd.StringVar(&cmd.split, "split", splitExpand, "show split transaction counterparts as: expand, list, first (non-aggregated)")
 	cmd.BoolVar(&cmd.showNotes, "n", false, "show transaction notes if present (non-aggregated)")
+	// sorting options
+	cmd.StringVar(&cmd.sortBy, "sort", "", "sort postings by: date, amount, abs, payee, account (non-aggregated)")
+	cmd.IntVar(&cmd.limit, "limit", 0, "list only this many postings, 0 means all (non-aggregated)")
+	cmd.BoolVar(&cmd.reverse, "reverse", false, "list postings in reverse order (non-aggregated)")
 	return &cmd
 }
 
 func (cmd *cmdRegister) init() {
 	check.If(cmd.NArg() > 0, "account filter is required")
+	check.If(cmd.split == splitExpand || cmd.split == splitList || cmd.split == splitFirst,
+		"invalid -split %s, use expand, list or first", cmd.split)
 	coin.LoadAll()
 }
 
 func (cmd *cmdRegister) execute(f io.Writer) {
 	pattern := cmd.Arg(0)
 	acc := coin.MustFindAccount(pattern)
-	if cmd.output == "text" {
+	if cmd.output == outText || cmd.output == outChart {
 		fmt.Fprintln(f, acc.FullName, acc.Commodity.Id)
 	}
 	if by := cmd.period(); by != nil {
-		if cmd.recurse {
+		if cmd.pivot != "account" {
+			cmd.pivotAggregatedRegister(f, acc, by)
+		} else if cmd.recurse {
 			cmd.recursiveAggregatedRegister(f, acc, by)
 		} else {
 			cmd.flatAggregatedRegister(f, acc, by)
@@ -85,6 +108,8 @@ func (cmd *cmdRegister) execute(f io.Writer) {
 			location:  cmd.location,
 			commodity: acc.Commodity,
 			showNotes: cmd.showNotes,
+			split:     cmd.split,
+			effective: cmd.effective,
 		}
 		if cmd.recurse {
 			var ps postings
@@ -92,11 +117,21 @@ func (cmd *cmdRegister) execute(f io.Writer) {
 				ps = append(ps, cmd.trim(a.Postings)...)
 			})
 			sort.SliceStable(ps, func(i, j int) bool {
-				return ps[i].Transaction.Posted.Before(ps[j].Transaction.Posted)
+				return ps[i].Time(cmd.effective).Before(ps[j].Time(cmd.effective))
 			})
-			ps.printLong(f, &opts)
+			ps = cmd.sort(ps, &opts)
+			if cmd.output == outText {
+				ps.printLong(f, &opts)
+			} else {
+				ps.rows(&opts).write(f, cmd.output)
+			}
 		} else {
-			cmd.trim(acc.Postings).print(f, &opts)
+			ps := cmd.sort(cmd.trim(acc.Postings), &opts)
+			if cmd.output == outText {
+				ps.print(f, &opts)
+			} else {
+				ps.rows(&opts).write(f, cmd.output)
+			}
 		}
 	}
 }
@@ -106,7 +141,7 @@ func (cmd *cmdRegister) flatAggregatedRegister(f io.Writer, acc *coin.Account, b
 	acc.WithChildrenDo(func(a *coin.Account) {
 		ts := totals.newTotals(a, by)
 		for _, p := range cmd.trim(a.Postings) {
-			ts.add(p.Transaction.Posted, p.Quantity)
+			ts.add(p.Date(cmd.effective), p.Quantity)
 		}
 	})
 	var accounts []*coin.Account
@@ -119,10 +154,15 @@ func (cmd *cmdRegister) flatAggregatedRegister(f io.Writer, acc *coin.Account, b
 	if cmd.cumulative {
 		totals.makeCumulative()
 	}
+	if cmd.average > 0 {
+		accounts = cmd.addMovingAverage(totals, accounts, totals.sum(by))
+	}
 	label := func(a *coin.Account) string {
 		switch a {
 		case nil:
 			return "Other"
+		case averageColumn:
+			return cmd.averageLabel()
 		case acc:
 			return acc.Name
 		default:
@@ -130,7 +170,7 @@ func (cmd *cmdRegister) flatAggregatedRegister(f io.Writer, acc *coin.Account, b
 			return coin.ShortenAccountName(n, cmd.maxLabelWidth)
 		}
 	}
-	totals.output(f, accounts, label, cmd.output)
+	totals.output(f, accounts, label, cmd.totalsOutput(acc, 0))
 }
 
 func (cmd *cmdRegister) recursiveAggregatedRegister(f io.Writer, acc *coin.Account, by *reducer) {
@@ -138,7 +178,7 @@ func (cmd *cmdRegister) recursiveAggregatedRegister(f io.Writer, acc *coin.Accou
 	acc.WithChildrenDo(func(a *coin.Account) {
 		ts := totals.newTotals(a, by)
 		for _, p := range cmd.trim(a.Postings) {
-			ts.add(p.Transaction.Posted, p.Quantity)
+			ts.add(p.Date(cmd.effective), p.Quantity)
 		}
 	})
 	if cmd.recurse {
@@ -162,10 +202,15 @@ func (cmd *cmdRegister) recursiveAggregatedRegister(f io.Writer, acc *coin.Accou
 	if cmd.cumulative {
 		totals.makeCumulative()
 	}
+	if cmd.average > 0 {
+		accounts = cmd.addMovingAverage(totals, accounts, accTotals)
+	}
 	label := func(a *coin.Account) string {
 		switch a {
 		case nil:
 			return "Other"
+		case averageColumn:
+			return cmd.averageLabel()
 		case acc:
 			return "Totals"
 		default:
@@ -173,7 +218,133 @@ func (cmd *cmdRegister) recursiveAggregatedRegister(f io.Writer, acc *coin.Accou
 			return coin.ShortenAccountName(n, cmd.maxLabelWidth)
 		}
 	}
-	totals.output(f, accounts, label, cmd.output)
+	totals.output(f, accounts, label, cmd.totalsOutput(acc, 1))
+}
+
+// group identifies a group of postings in a pivoted aggregation
+type group struct {
+	name string
+}
+
+var (
+	// keys of the totals and average columns in pivoted aggregated totals
+	totalsGroup  = &group{name: "Totals"}
+	averageGroup = &group{name: "Average"}
+)
+
+// pivotAggregatedRegister aggregates postings of the account and its subaccounts
+// grouped by the pivot key (payee or tag value) instead of by account.
+func (cmd *cmdRegister) pivotAggregatedRegister(f io.Writer, acc *coin.Account, by *reducer) {
+	key := cmd.pivotKey()
+	var ps postings
+	acc.WithChildrenDo(func(a *coin.Account) {
+		ps = append(ps, cmd.trim(a.Postings)...)
+	})
+	sort.SliceStable(ps, func(i, j int) bool {
+		return ps[i].Time(cmd.effective).Before(ps[j].Time(cmd.effective))
+	})
+	groups := map[string]*group{}
+	gts := groupTotals[*group]{}
+	all := &totals{reducer: by}
+	for _, p := range ps {
+		name := key(p)
+		g := groups[name]
+		if g == nil {
+			g = &group{name: name}
+			groups[name] = g
+			gts.newTotals(g, by)
+		}
+		gts[g].add(p.Date(cmd.effective), p.Quantity)
+		all.add(p.Date(cmd.effective), p.Quantity)
+	}
+	check.If(len(ps) > 0, "no postings to aggregate\n")
+	gts, order := gts.top(cmd.top)
+	gts.mergeTime(all)
+	gts[totalsGroup] = all
+	order = append(order, totalsGroup)
+	if cmd.cumulative {
+		gts.makeCumulative()
+	}
+	if cmd.average > 0 {
+		gts[averageGroup] = all.movingAverage(cmd.average)
+		order = append(order, averageGroup)
+	}
+	label := func(g *group) string {
+		switch g {
+		case nil:
+			return "Other"
+		case averageGroup:
+			return cmd.averageLabel()
+		default:
+			return coin.ShortenAccountName(g.name, cmd.maxLabelWidth)
+		}
+	}
+	gts.output(f, order, label, cmd.totalsOutput(acc, 1))
+}
+
+// totalsOutput returns the aggregated output configuration,
+// summary is the number of trailing total columns (not counting the average).
+func (cmd *cmdRegister) totalsOutput(acc *coin.Account, summary int) totalsOutput {
+	if cmd.average > 0 {
+		summary++
+	}
+	kind := cmd.kind
+	if kind == "" {
+		kind = chartBar
+		if cmd.output == outSVG || cmd.output == outHTML {
+			kind = chartLine
+		}
+	}
+	return totalsOutput{
+		title:   acc.FullName + " " + acc.Commodity.Id,
+		format:  cmd.output,
+		kind:    kind,
+		stats:   cmd.stats,
+		summary: summary,
+	}
+}
+
+// pivotKey returns function computing the pivot group name of a posting.
+func (cmd *cmdRegister) pivotKey() func(p *coin.Posting) string {
+	switch {
+	case cmd.pivot == "payee":
+		return func(p *coin.Posting) string {
+			if p.Transaction.Description == "" {
+				return "(none)"
+			}
+			return p.Transaction.Description
+		}
+	case strings.HasPrefix(cmd.pivot, "tag:"):
+		tag := strings.TrimPrefix(cmd.pivot, "tag:")
+		return func(p *coin.Posting) string {
+			tags := p.Tags
+			if !tags.Includes(tag) {
+				tags = p.Transaction.Tags
+			}
+			if !tags.Includes(tag) {
+				return "(none)"
+			}
+			if v := tags.Value(tag); v != "" {
+				return v
+			}
+			return "#" + tag
+		}
+	}
+	check.If(false, "unknown aggregation key %s\n", cmd.pivot)
+	return nil
+}
+
+// averageColumn is the key of the moving average column in aggregated totals
+var averageColumn = &coin.Account{Name: "Average"}
+
+// addMovingAverage adds the moving average of ts as the last column of the totals.
+func (cmd *cmdRegister) addMovingAverage(totals accountTotals, order []*coin.Account, ts *totals) []*coin.Account {
+	totals[averageColumn] = ts.movingAverage(cmd.average)
+	return append(order, averageColumn)
+}
+
+func (cmd *cmdRegister) averageLabel() string {
+	return fmt.Sprintf("Avg(%d)", cmd.average)
 }
 
 func (cmd *cmdRegister) period() *reducer {
@@ -191,7 +362,7 @@ func (cmd *cmdRegister) period() *reducer {
 }
 
 func (cmd *cmdRegister) trim(ps []*coin.Posting) postings {
-	ps = trim(ps, cmd.begin, cmd.end)
+	ps = trim(ps, cmd.begin, cmd.end, cmd.effective)
 	if len(cmd.payee) > 0 {
 		inverted := false
 		if cmd.payee[0] == '!' {
@@ -220,6 +391,37 @@ func (cmd *cmdRegister) trim(ps []*coin.Posting) postings {
 	return postings(ps)
 }
 
+// sort reorders and limits the postings as requested by the sorting options.
+// Running totals are computed beforehand in date order and carried with the postings.
+func (cmd *cmdRegister) sort(ps postings, opts *options) postings {
+	if cmd.sortBy == "" && cmd.limit == 0 && !cmd.reverse {
+		return ps
+	}
+	commodity := opts.commodity
+	if commodity == nil && len(ps) > 0 {
+		commodity = ps[0].Account.Commodity
+	}
+	// sort a copy, ps may share the backing array with account postings
+	ps = append(postings(nil), ps...)
+	sorted := &sortedPostings{postings: ps, totals: ps.totals(commodity), effective: cmd.effective}
+	if cmd.sortBy != "" {
+		sorted.less = postingOrder[cmd.sortBy]
+		check.If(sorted.less != nil, "unknown sort key %s", cmd.sortBy)
+		sort.Stable(sorted)
+	}
+	if cmd.reverse {
+		for i, j := 0, len(ps)-1; i < j; i, j = i+1, j-1 {
+			sorted.Swap(i, j)
+		}
+	}
+	if cmd.limit > 0 && cmd.limit < len(ps) {
+		sorted.postings = sorted.postings[:cmd.limit]
+		sorted.totals = sorted.totals[:cmd.limit]
+	}
+	opts.totals = sorted.totals
+	return sorted.postings
+}
+
 func (cmd *cmdRegister) debugf(format string, args ...interface{}) {
 	if !cmd.verbose {
 		return
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/template"
+	"time"
+
+	"github.com/mkobetic/coin"
+	"github.com/mkobetic/coin/check"
+)
+
+func init() {
+	(&cmdReport{}).newCommand("report", "rep")
+}
+
+type cmdReport struct {
+	flagsWithUsage
+	template   string
+	begin, end coin.Date
+	effective  bool
+}
+
+func (*cmdReport) newCommand(names ...string) command {
+	var cmd cmdReport
+	cmd.FlagSet = newCommand(&cmd, names...)
+	setUsage(cmd.FlagSet, `(report|rep) [flags] -t TEMPLATE [account]
+
+Executes a user defined report template for account (default: Root).
+Template is a Go text/template file, it is looked up as specified
+and in $COINDB/reports/, with or without the .tmpl extension.
+See README.md for the available data and functions.`)
+	cmd.StringVar(&cmd.template, "t", "", "report template file")
+	cmd.Var(&cmd.begin, "b", "begin report from this date")
+	cmd.Var(&cmd.end, "e", "end report on this date")
+	cmd.BoolVar(&cmd.effective, "effective", false, "use effective dates of postings (DATE=DATE2, [=DATE])")
+	return &cmd
+}
+
+func (cmd *cmdReport) init() {
+	coin.LoadAll()
+}
+
+func (cmd *cmdReport) execute(f io.Writer) {
+	check.If(cmd.template != "", "report template is required\n")
+	account := coin.Root
+	if cmd.NArg() > 0 {
+		account = coin.MustFindAccount(cmd.Arg(0))
+	}
+	fn := findReportTemplate(cmd.template)
+	t, err := template.New(filepath.Base(fn)).Funcs(cmd.funcs()).ParseFiles(fn)
+	check.NoError(err, "parsing report template %s", fn)
+	err = t.Execute(f, cmd.data(account))
+	check.NoError(err, "executing report template %s", fn)
+}
+
+// findReportTemplate returns the path of the named template,
+// trying also the reports directory of the ledger and the .tmpl extension.
+func findReportTemplate(name string) string {
+	candidates := []string{name, name + ".tmpl"}
+	if !filepath.IsAbs(name) {
+		reports := filepath.Join(coin.DB, "reports", name)
+		candidates = append(candidates, reports, reports+".tmpl")
+	}
+	for _, fn := range candidates {
+		if fi, err := os.Stat(fn); err == nil && !fi.IsDir() {
+			return fn
+		}
+	}
+	check.If(false, "cannot find report template %s\n", name)
+	return ""
+}
+
+// reportData is the data model of report templates
+type reportData struct {
+	Account    *coin.Account   // the selected account
+	Accounts   []*coin.Account // the selected account and all its subaccounts
+	Postings   []*coin.Posting // postings of the selected accounts in the time range ordered by date
+	Begin, End time.Time       // the time range of the report, zero if not specified
+	Commodity  *coin.Commodity // the default commodity
+}
+
+func (cmd *cmdReport) data(account *coin.Account) *reportData {
+	data := &reportData{
+		Account:   account,
+		Begin:     cmd.begin.Time,
+		End:       cmd.end.Time,
+		Commodity: coin.DefaultCommodity(),
+	}
+	account.WithChildrenDo(func(a *coin.Account) {
+		data.Accounts = append(data.Accounts, a)
+	})
+	data.Postings = cmd.postings(account)
+	return data
+}
+
+// postings returns the postings of the account and its subaccounts
+// in the time range ordered by date
+func (cmd *cmdReport) postings(account *coin.Account) (ps []*coin.Posting) {
+	account.WithChildrenDo(func(a *coin.Account) {
+		ps = append(ps, cmd.trim(a.Postings)...)
+	})
+	sort.SliceStable(ps, func(i, j int) bool {
+		return ps[i].Time(cmd.effective).Before(ps[j].Time(cmd.effective))
+	})
+	return ps
+}
+
+func (cmd *cmdReport) trim(ps []*coin.Posting) postings {
+	return trim(ps, cmd.begin, cmd.end, cmd.effective)
+}
+
+// reportTotal is the total of postings for a period
+type reportTotal struct {
+	Period string    // period label, e.g. 2010/03 for a month
+	Time   time.Time // start of the period
+	Amount *coin.Amount
+}
+
+// reportPeriods maps the period names accepted by the totals template function to reducers
+var reportPeriods = map[string]*reducer{
+	"week":    &week,
+	"month":   &month,
+	"quarter": &quarter,
+	"year":    &year,
+}
+
+// funcs returns the functions available in report templates
+func (cmd *cmdReport) funcs() template.FuncMap {
+	return template.FuncMap{
+		"amount": func(a *coin.Amount) string {
+			return fmt.Sprintf("%a", a)
+		},
+		"money": func(a *coin.Amount) string {
+			return fmt.Sprintf("%a %s", a, a.Commodity.Id)
+		},
+		"date": func(t time.Time) string {
+			return t.Format(coin.Output.DateFormat)
+		},
+		"dateFmt": func(layout string, t time.Time) string {
+			return t.Format(layout)
+		},
+		"account": func(pattern string) *coin.Account {
+			return coin.MustFindAccount(pattern)
+		},
+		"postings": func(a *coin.Account) []*coin.Posting {
+			return cmd.postings(a)
+		},
+		"total": func(a *coin.Account) *coin.Amount {
+			totals, _ := accountBalances(a, cmd.trim)
+			return totals[a]
+		},
+		"balance": func(a *coin.Account) *coin.Amount {
+			_, cumulative := accountBalances(a, cmd.trim)
+			return cumulative[a]
+		},
+		"totals": func(period string, a *coin.Account) (rts []*reportTotal) {
+			by := reportPeriods[period]
+			check.If(by != nil, "unknown period %s, use week, month, quarter or year\n", period)
+			ts := &totals{reducer: by}
+			for _, p := range cmd.postings(a) {
+				ts.add(p.Date(cmd.effective), p.Quantity)
+			}
+			for _, t := range ts.all {
+				rts = append(rts, &reportTotal{Period: t.Time.Format(by.format), Time: t.Time, Amount: t.Amount})
+			}
+			return rts
+		},
+		"tag": func(p *coin.Posting, name string) string {
+			if p.Tags.Includes(name) {
+				return p.Tags.Value(name)
+			}
+			return p.Transaction.Tags.Value(name)
+		},
+	}
+}
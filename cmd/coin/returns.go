@@ -0,0 +1,265 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/mkobetic/coin"
+	"github.com/mkobetic/coin/check"
+	"github.com/mkobetic/coin/check/warn"
+)
+
+func init() {
+	(&cmdReturns{}).newCommand("returns", "ret")
+}
+
+type cmdReturns struct {
+	flagsWithUsage
+	begin, end        coin.Date
+	weekly, monthly   bool
+	quarterly, yearly bool
+	income, expenses  string
+	effective         bool
+	output            string
+}
+
+func (*cmdReturns) newCommand(names ...string) command {
+	var cmd cmdReturns
+	cmd.FlagSet = newCommand(&cmd, names...)
+	setUsage(cmd.FlagSet, `(returns|ret) [flags] account
+
+Lists time-weighted (TWR) and money-weighted (XIRR, annualized) returns of account
+and its subaccounts for each period (default: yearly) and since inception.
+External cash flows are postings whose counterpart is outside the account,
+except for income and expense accounts which are considered part of the return.
+Values are in the default commodity using the latest prices before each date.`)
+	cmd.Var(&cmd.begin, "b", "begin with the period including this date (default: first posting)")
+	cmd.Var(&cmd.end, "e", "end with the period before this date (default: last posting)")
+	cmd.BoolVar(&cmd.weekly, "w", false, "list returns by week")
+	cmd.BoolVar(&cmd.monthly, "m", false, "list returns by month")
+	cmd.BoolVar(&cmd.quarterly, "q", false, "list returns by quarter")
+	cmd.BoolVar(&cmd.yearly, "y", false, "list returns by year")
+	cmd.StringVar(&cmd.income, "income", "Income", "income account")
+	cmd.StringVar(&cmd.expenses, "expenses", "Expenses", "expenses account")
+	cmd.BoolVar(&cmd.effective, "effective", false, "use effective dates of postings (DATE=DATE2, [=DATE])")
+	outputFlag(cmd.FlagSet, &cmd.output)
+	return &cmd
+}
+
+func (cmd *cmdReturns) init() {
+	coin.LoadAll()
+}
+
+func (cmd *cmdReturns) execute(f io.Writer) {
+	check.If(cmd.NArg() > 0, "account is required\n")
+	account := coin.MustFindAccount(cmd.Arg(0))
+	by := cmd.period()
+	first, last := timeRange(cmd.begin, cmd.end, cmd.effective, account)
+	check.If(!first.IsZero() && !last.IsZero(), "no postings to report\n")
+	flows := cmd.flows(account)
+	var rs []*periodReturn
+	inception := &periodReturn{period: "Total", start: by.reduce(first)}
+	for start := inception.start; start.Before(last); start = nextPeriod(by, start) {
+		end := nextPeriod(by, start)
+		if !cmd.end.IsZero() && cmd.end.Before(end) {
+			end = cmd.end.Time
+		}
+		r := newPeriodReturn(start.Format(by.format), account, start, end, flows, cmd.effective)
+		rs = append(rs, r)
+		inception.end = end
+	}
+	inception = newPeriodReturn(inception.period, account, inception.start, inception.end, flows, cmd.effective)
+	rs = append(rs, inception)
+	returnRows(rs).write(f, cmd.output)
+}
+
+func (cmd *cmdReturns) period() *reducer {
+	switch {
+	case cmd.weekly:
+		return &week
+	case cmd.monthly:
+		return &month
+	case cmd.quarterly:
+		return &quarter
+	}
+	return &year
+}
+
+// cashFlow is an external cash flow into (positive) or out of (negative) the account
+type cashFlow struct {
+	time   time.Time
+	amount *coin.Amount
+}
+
+// flows returns the external cash flows of the account and its subaccounts ordered by date,
+// flows on the same date are combined.
+func (cmd *cmdReturns) flows(account *coin.Account) (flows []*cashFlow) {
+	dc := coin.DefaultCommodity()
+	internal := map[*coin.Account]bool{}
+	for _, acc := range []*coin.Account{account, coin.AccountsByName[cmd.income], coin.AccountsByName[cmd.expenses]} {
+		if acc != nil {
+			acc.WithChildrenDo(func(a *coin.Account) { internal[a] = true })
+		}
+	}
+	seen := map[*coin.Transaction]bool{}
+	byTime := map[time.Time]*cashFlow{}
+	account.WithChildrenDo(func(a *coin.Account) {
+		for _, p := range a.Postings {
+			t := p.Transaction
+			if seen[t] {
+				continue
+			}
+			seen[t] = true
+			// the flow takes effect when the account posting does
+			date := p.Date(cmd.effective)
+			for _, s := range t.Postings {
+				if internal[s.Account] {
+					continue
+				}
+				amount, err := dc.ConvertAt(s.Quantity, s.Quantity.Commodity, flowPricesAt(t.Posted))
+				if err != nil {
+					warn.If(true, "%s: %s, flow excluded\n", t.Location(), err)
+					continue
+				}
+				flow := byTime[date]
+				if flow == nil {
+					flow = &cashFlow{time: date, amount: coin.NewZeroAmount(dc)}
+					byTime[date] = flow
+					flows = append(flows, flow)
+				}
+				// the counterpart posting is negated to get the flow into the account
+				flow.amount.Sub(flow.amount.Int, amount.Int)
+			}
+		}
+	})
+	sort.Slice(flows, func(i, j int) bool {
+		return flows[i].time.Before(flows[j].time)
+	})
+	return flows
+}
+
+// flowPricesAt returns the price cutoff for flows posted at t,
+// prices posted on the transaction date are included.
+func flowPricesAt(t time.Time) time.Time {
+	return t.AddDate(0, 0, 1)
+}
+
+// periodReturn captures the performance of the account in a period
+type periodReturn struct {
+	period          string
+	start, end      time.Time
+	startValue      *coin.Amount
+	endValue        *coin.Amount
+	flows           *coin.Amount // net external cash flows
+	gain            *coin.Amount // end value - start value - flows
+	twr, xirr       float64
+	hasTWR, hasXIRR bool
+}
+
+func newPeriodReturn(period string, account *coin.Account, start, end time.Time, flows []*cashFlow, effective bool) *periodReturn {
+	r := &periodReturn{
+		period:     period,
+		start:      start,
+		end:        end,
+		startValue: valueAt(account, start, effective),
+		endValue:   valueAt(account, end, effective),
+	}
+	r.flows = coin.NewZeroAmount(r.startValue.Commodity)
+	// time-weighted return chains the returns of sub-periods between the flows
+	growth, previous := 1.0, float(r.startValue)
+	// money-weighted return discounts the flows from the investor's perspective
+	xflows := []xirrFlow{{start, -float(r.startValue)}}
+	for _, flow := range flows {
+		if flow.time.Before(start) || !flow.time.Before(end) {
+			continue
+		}
+		// the value before the flow uses the same prices as the flow itself
+		before := float(valueWithPricesAt(account, flow.time, flowPricesAt(flow.time), effective))
+		if previous != 0 {
+			growth *= before / previous
+			r.hasTWR = true
+		}
+		previous = before + float(flow.amount)
+		r.flows.Add(r.flows.Int, flow.amount.Int)
+		xflows = append(xflows, xirrFlow{flow.time, -float(flow.amount)})
+	}
+	if previous != 0 {
+		growth *= float(r.endValue) / previous
+		r.hasTWR = true
+	}
+	r.twr = growth - 1
+	xflows = append(xflows, xirrFlow{end, float(r.endValue)})
+	r.xirr, r.hasXIRR = xirr(xflows)
+	r.gain = r.endValue.Copy()
+	r.gain.Sub(r.gain.Int, r.startValue.Int)
+	r.gain.Sub(r.gain.Int, r.flows.Int)
+	return r
+}
+
+type xirrFlow struct {
+	time   time.Time
+	amount float64
+}
+
+// xirr returns the annualized internal rate of return of the flows,
+// false if there isn't one. Uses bisection which is slow but robust.
+func xirr(flows []xirrFlow) (float64, bool) {
+	var in, out bool
+	for _, f := range flows {
+		in, out = in || f.amount < 0, out || f.amount > 0
+	}
+	if !in || !out {
+		return 0, false
+	}
+	npv := func(rate float64) (v float64) {
+		for _, f := range flows {
+			years := f.time.Sub(flows[0].time).Hours() / 24 / 365
+			v += f.amount / math.Pow(1+rate, years)
+		}
+		return v
+	}
+	lo, hi := -0.9999, 100.0
+	vlo, vhi := npv(lo), npv(hi)
+	if math.Signbit(vlo) == math.Signbit(vhi) {
+		return 0, false
+	}
+	for i := 0; i < 200 && hi-lo > 1e-10; i++ {
+		mid := (lo + hi) / 2
+		if vmid := npv(mid); math.Signbit(vmid) == math.Signbit(vlo) {
+			lo, vlo = mid, vmid
+		} else {
+			hi = mid
+		}
+	}
+	return (lo + hi) / 2, true
+}
+
+func formatReturn(r float64, ok bool) string {
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("%.2f%%", r*100)
+}
+
+func (r *periodReturn) cells() []string {
+	return []string{
+		r.period,
+		r.startValue.String(),
+		r.flows.String(),
+		r.endValue.String(),
+		r.gain.String(),
+		formatReturn(r.twr, r.hasTWR),
+		formatReturn(r.xirr, r.hasXIRR),
+	}
+}
+
+func returnRows(rs []*periodReturn) (rs2 rows) {
+	rs2 = append(rs2, []string{"Period", "Start", "Flows", "End", "Gain", "TWR", "XIRR", "Commodity"})
+	for _, r := range rs {
+		rs2 = append(rs2, append(r.cells(), r.gain.Commodity.Id))
+	}
+	return rs2
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"io"
 	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/mkobetic/coin"
 )
@@ -18,6 +20,7 @@ type cmdStats struct {
 	unbalanced          bool
 	commodityMismatches bool
 	begin, end          coin.Date
+	output              string
 }
 
 func (*cmdStats) newCommand(names ...string) command {
@@ -31,6 +34,7 @@ Perform checks and print statistics about the ledger.`)
 	cmd.BoolVar(&cmd.commodityMismatches, "c", false, "check for commodity mismatches")
 	cmd.Var(&cmd.begin, "b", "begin register from this date")
 	cmd.Var(&cmd.end, "e", "end register on this date")
+	outputFlag(cmd.FlagSet, &cmd.output)
 	return &cmd
 }
 
@@ -39,8 +43,83 @@ func (cmd *cmdStats) init() {
 }
 
 func (cmd *cmdStats) execute(f io.Writer) {
-
 	transactions := cmd.transactions()
+	issues := cmd.check(transactions)
+	if cmd.output != outText {
+		if cmd.dupes || cmd.unbalanced || cmd.commodityMismatches {
+			issueRows(issues).write(f, cmd.output)
+		} else {
+			cmd.rows(transactions).write(f, cmd.output)
+		}
+		return
+	}
+	for _, i := range issues {
+		i.print(f)
+	}
+	if cmd.dupes {
+		return
+	}
+	for _, r := range cmd.rows(transactions)[1:] {
+		fmt.Fprintf(f, "%s: %s\n", r[0], r[1])
+	}
+}
+
+// issue is a problem with a transaction found by the checks
+type issue struct {
+	kind   string
+	t      *coin.Transaction
+	other  *coin.Transaction // the other transaction of a duplicate
+	detail string
+}
+
+const (
+	issueDuplicate  = "DUPLICATE TRANSACTION?"
+	issueConversion = "BAD CONVERSION"
+	issueUnbalanced = "UNBALANCED TRANSACTION!"
+)
+
+func (i *issue) print(f io.Writer) {
+	switch i.kind {
+	case issueDuplicate:
+		fmt.Fprintf(f, "%s\n%s\n%s\n%s\n%s\n",
+			i.kind,
+			i.other.Location(), i.other,
+			i.t.Location(), i.t)
+	case issueConversion:
+		fmt.Fprintf(f, "%s: %s %s : %s\n",
+			i.kind,
+			i.t.Posted.Format(coin.DateFormat),
+			i.detail,
+			i.t.Location())
+	default:
+		fmt.Fprintf(f, "%s\n%s\n%s\n", i.kind, i.t.Location(), i.t)
+	}
+}
+
+func issueRows(issues []*issue) (rs rows) {
+	rs = append(rs, []string{"Issue", "Date", "Description", "Location", "Other"})
+	for _, i := range issues {
+		var other string
+		if i.other != nil {
+			other = i.other.Location()
+		}
+		description := i.t.Description
+		if len(i.detail) > 0 {
+			description = i.detail
+		}
+		rs = append(rs, []string{
+			strings.TrimRight(i.kind, "?!"),
+			i.t.Posted.Format(coin.DateFormat),
+			description,
+			i.t.Location(),
+			other,
+		})
+	}
+	return rs
+}
+
+// check returns the issues found by the requested checks
+func (cmd *cmdStats) check(transactions []*coin.Transaction) (issues []*issue) {
 	if cmd.dupes {
 		var day []*coin.Transaction
 		for _, t := range transactions {
@@ -54,46 +133,47 @@ func (cmd *cmdStats) execute(f io.Writer) {
 			}
 			for _, t2 := range day {
 				if t.IsEqual(t2) {
-					fmt.Fprintf(f,
-						"DUPLICATE TRANSACTION?\n%s\n%s\n%s\n%s\n",
-						t2.Location(), t2,
-						t.Location(), t)
+					issues = append(issues, &issue{kind: issueDuplicate, t: t, other: t2})
 				}
 			}
 			day = append(day, t)
 		}
-		return
+		return issues
 	}
 
 	for _, t := range transactions {
 		if cmd.commodityMismatches && len(t.Postings) == 2 {
 			if p1, p2 := t.Postings[0], t.Postings[1]; p1.Quantity.IsEqual(p2.Quantity.Negated()) &&
 				p1.Quantity.Commodity != p2.Quantity.Commodity {
-				fmt.Fprintf(f,
-					"BAD CONVERSION: %s %a %s => %a %s : %s\n",
-					t.Posted.Format(coin.DateFormat),
-					p1.Quantity,
-					p1.Quantity.Commodity.Id,
-					p2.Quantity,
-					p2.Quantity.Commodity.Id,
-					t.Location(),
-				)
+				issues = append(issues, &issue{
+					kind: issueConversion,
+					t:    t,
+					detail: fmt.Sprintf("%a %s => %a %s",
+						p1.Quantity,
+						p1.Quantity.Commodity.Id,
+						p2.Quantity,
+						p2.Quantity.Commodity.Id,
+					),
+				})
 			}
 		}
 		for _, p := range t.Postings {
 			if cmd.unbalanced && p.Account == coin.Unbalanced {
-				fmt.Fprintf(f,
-					"UNBALANCED TRANSACTION!\n%s\n%s\n",
-					t.Location(),
-					t)
+				issues = append(issues, &issue{kind: issueUnbalanced, t: t})
 			}
 		}
 	}
+	return issues
+}
 
-	fmt.Fprintln(f, "Commodities:", len(coin.Commodities))
-	fmt.Fprintln(f, "Prices:", len(coin.Prices))
-	fmt.Fprintln(f, "Accounts:", len(coin.AccountsByName))
-	fmt.Fprintln(f, "Transactions:", len(transactions))
+func (cmd *cmdStats) rows(transactions []*coin.Transaction) rows {
+	return rows{
+		{"Statistic", "Count"},
+		{"Commodities", strconv.Itoa(len(coin.Commodities))},
+		{"Prices", strconv.Itoa(len(coin.Prices))},
+		{"Accounts", strconv.Itoa(len(coin.AccountsByName))},
+		{"Transactions", strconv.Itoa(len(transactions))},
+	}
 }
 
 func (cmd *cmdStats) transactions() []*coin.Transaction {
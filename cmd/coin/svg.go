@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/mkobetic/coin/check"
+)
+
+// Standalone chart output formats and kinds
+const (
+	outSVG  = "svg"
+	outHTML = "html"
+
+	chartLine = "line" // a line per series
+	chartArea = "area" // series stacked as areas
+)
+
+// svg chart layout
+const (
+	svgWidth, svgHeight           = 800, 400
+	svgMarginLeft, svgMarginRight = 80, 160
+	svgMarginTop, svgMarginBottom = 20, 40
+	svgPlotWidth                  = svgWidth - svgMarginLeft - svgMarginRight
+	svgPlotHeight                 = svgHeight - svgMarginTop - svgMarginBottom
+	svgTicks                      = 5
+	svgMaxLabels                  = 12
+	svgFont                       = `font-family="sans-serif" font-size="12"`
+)
+
+// svgColors is the d3 category10 color scheme
+var svgColors = []string{
+	"#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd",
+	"#8c564b", "#e377c2", "#7f7f7f", "#bcbd22", "#17becf",
+}
+
+func (c *chart) color(series int) string {
+	return svgColors[series%len(svgColors)]
+}
+
+// stacked returns the number of series that are stacked in stacked charts
+func (c *chart) stacked() int {
+	return max(1, len(c.series)-c.summary)
+}
+
+// writeSVG draws the chart as a standalone svg document,
+// either as lines or as stacked areas over the rows.
+func (c *chart) writeSVG(f io.Writer, kind string) {
+	check.If(len(c.rows) > 0, "nothing to chart\n")
+	// compute the values to plot, [series][row]
+	var lines [][]float64
+	var series []string
+	switch kind {
+	case chartLine:
+		series = c.series
+		for j := range series {
+			var line []float64
+			for i := range c.rows {
+				line = append(line, c.value(i, j))
+			}
+			lines = append(lines, line)
+		}
+	case chartArea:
+		series = c.series[:c.stacked()]
+		cum := make([]float64, len(c.rows))
+		for j := range series {
+			var line []float64
+			for i := range c.rows {
+				cum[i] += c.value(i, j)
+				line = append(line, cum[i])
+			}
+			lines = append(lines, line)
+		}
+	default:
+		check.If(false, "unknown svg chart kind %s", kind)
+	}
+	minV, maxV := 0.0, 0.0
+	for _, line := range lines {
+		for _, v := range line {
+			minV, maxV = math.Min(minV, v), math.Max(maxV, v)
+		}
+	}
+	step := niceStep((maxV - minV) / svgTicks)
+	minV, maxV = math.Floor(minV/step)*step, math.Ceil(maxV/step)*step
+	if maxV == minV {
+		maxV = minV + step
+	}
+	x := func(i int) float64 {
+		if len(c.rows) == 1 {
+			return svgMarginLeft + svgPlotWidth/2
+		}
+		return svgMarginLeft + float64(i)*svgPlotWidth/float64(len(c.rows)-1)
+	}
+	y := func(v float64) float64 {
+		return svgMarginTop + (maxV-v)/(maxV-minV)*svgPlotHeight
+	}
+
+	fmt.Fprintf(f, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n",
+		svgWidth, svgHeight, svgWidth, svgHeight)
+	if c.title != "" {
+		fmt.Fprintf(f, "<title>%s</title>\n", html.EscapeString(c.title))
+	}
+	// value axis with grid lines
+	fmt.Fprintf(f, `<g class="axis" %s text-anchor="end">`+"\n", svgFont)
+	decimals := max(0, -int(math.Floor(math.Log10(step))))
+	for i := 0; i <= int(math.Round((maxV-minV)/step)); i++ {
+		v := minV + float64(i)*step
+		fmt.Fprintf(f, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="#ddd"/>`+"\n",
+			svgMarginLeft, y(v), svgMarginLeft+svgPlotWidth, y(v))
+		fmt.Fprintf(f, `<text x="%d" y="%.1f">%s</text>`+"\n",
+			svgMarginLeft-5, y(v)+4, strconv.FormatFloat(v, 'f', decimals, 64))
+	}
+	fmt.Fprintln(f, "</g>")
+	// period axis
+	fmt.Fprintf(f, `<g class="axis" %s text-anchor="middle">`+"\n", svgFont)
+	fmt.Fprintf(f, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="black"/>`+"\n",
+		svgMarginLeft, y(0), svgMarginLeft+svgPlotWidth, y(0))
+	every := (len(c.rows) + svgMaxLabels - 1) / svgMaxLabels
+	for i, r := range c.rows {
+		if i%every == 0 {
+			fmt.Fprintf(f, `<text x="%.1f" y="%d">%s</text>`+"\n",
+				x(i), svgHeight-svgMarginBottom+20, html.EscapeString(r))
+		}
+	}
+	fmt.Fprintln(f, "</g>")
+	// series, areas are drawn top down so that lower areas overlay the higher ones
+	for j := range lines {
+		if kind == chartArea {
+			j = len(lines) - 1 - j
+		}
+		var points []string
+		for i, v := range lines[j] {
+			points = append(points, fmt.Sprintf("%.1f,%.1f", x(i), y(v)))
+		}
+		if kind == chartLine {
+			fmt.Fprintf(f, `<polyline class="series" fill="none" stroke="%s" stroke-width="2" points="%s"/>`+"\n",
+				c.color(j), strings.Join(points, " "))
+			continue
+		}
+		points = append(points, fmt.Sprintf("%.1f,%.1f", x(len(c.rows)-1), y(0)))
+		points = append(points, fmt.Sprintf("%.1f,%.1f", x(0), y(0)))
+		fmt.Fprintf(f, `<polygon class="series" fill="%s" stroke="none" points="%s"/>`+"\n",
+			c.color(j), strings.Join(points, " "))
+	}
+	// legend
+	fmt.Fprintf(f, `<g class="legend" %s>`+"\n", svgFont)
+	for j, s := range series {
+		ly := svgMarginTop + j*20
+		fmt.Fprintf(f, `<rect x="%d" y="%d" width="12" height="12" fill="%s"/>`+"\n",
+			svgWidth-svgMarginRight+20, ly, c.color(j))
+		fmt.Fprintf(f, `<text x="%d" y="%d">%s</text>`+"\n",
+			svgWidth-svgMarginRight+38, ly+11, html.EscapeString(s))
+	}
+	fmt.Fprintln(f, "</g>")
+	fmt.Fprintln(f, "</svg>")
+}
+
+// niceStep rounds the step up to 1, 2 or 5 times a power of 10
+func niceStep(step float64) float64 {
+	if step <= 0 {
+		return 1
+	}
+	pow := math.Pow(10, math.Floor(math.Log10(step)))
+	for _, m := range []float64{1, 2, 5, 10} {
+		if step <= m*pow {
+			return m * pow
+		}
+	}
+	return 10 * pow
+}
+
+// writeHTML writes the chart as a self-contained html page
+// with the svg chart of the kind (line or area) inlined.
+func (c *chart) writeHTML(f io.Writer, kind string) {
+	check.If(len(c.rows) > 0, "nothing to chart\n")
+	check.If(kind == chartLine || kind == chartArea, "unknown svg chart kind %s", kind)
+	fmt.Fprintln(f, `<!DOCTYPE html>`)
+	fmt.Fprintln(f, `<html lang="en">`)
+	fmt.Fprintln(f, `<head>`)
+	fmt.Fprintln(f, `<meta charset="UTF-8" />`)
+	fmt.Fprintf(f, "<title>%s</title>\n", html.EscapeString(c.title))
+	fmt.Fprintln(f, `</head>`)
+	fmt.Fprintln(f, `<body>`)
+	c.writeSVG(f, kind)
+	fmt.Fprintln(f, `</body>`)
+	fmt.Fprintln(f, `</html>`)
+}
@@ -18,6 +18,7 @@ type cmdTags struct {
 	flagsWithUsage
 	fValues   bool
 	fAccounts bool
+	output    string
 
 	results  map[string][]string
 	accounts map[string][]string
@@ -31,6 +32,7 @@ func (*cmdTags) newCommand(names ...string) command {
 List tags matching the NAMEREX.`)
 	cmd.BoolVar(&cmd.fValues, "v", false, "print tag values if applicable")
 	cmd.BoolVar(&cmd.fAccounts, "a", false, "print account names where tag is used")
+	outputFlag(cmd.FlagSet, &cmd.output)
 	return &cmd
 }
 
@@ -55,6 +57,10 @@ func (cmd *cmdTags) execute(f io.Writer) {
 		}
 		cmd.collectKeys(nrex, t.Tags, accounts...)
 	}
+	if cmd.output != outText {
+		cmd.rows().write(f, cmd.output)
+		return
+	}
 	for _, k := range sortAndClean(cmd.results) {
 		vs := strings.Join(cmd.results[k], `", "`)
 		colon := ""
@@ -74,6 +80,30 @@ func (cmd *cmdTags) execute(f io.Writer) {
 	}
 }
 
+// rows lists a tag per row, values and accounts are included
+// as comma separated lists if requested.
+func (cmd *cmdTags) rows() (rs rows) {
+	header := []string{"Tag"}
+	if cmd.fValues {
+		header = append(header, "Values")
+	}
+	if cmd.fAccounts {
+		header = append(header, "Accounts")
+	}
+	rs = append(rs, header)
+	for _, k := range sortAndClean(cmd.results) {
+		row := []string{k}
+		if cmd.fValues {
+			row = append(row, strings.Join(cmd.results[k], ", "))
+		}
+		if cmd.fAccounts {
+			row = append(row, strings.Join(cmd.accounts[k], ", "))
+		}
+		rs = append(rs, row)
+	}
+	return rs
+}
+
 func (cmd *cmdTags) collectKeys(nrex *regexp.Regexp, tags coin.Tags, accounts ...*coin.Account) {
 	for k, v := range tags {
 		if nrex == nil || nrex.MatchString(k) {
@@ -1,10 +1,9 @@
 package main
 
 import (
-	"encoding/csv"
-	"encoding/json"
 	"fmt"
 	"io"
+	"math/big"
 	"sort"
 	"strings"
 	"time"
@@ -161,6 +160,92 @@ func (ts *totals) makeCumulative() {
 	}
 }
 
+// periods returns the totals of all periods from the first to the last one,
+// periods without postings have zero totals.
+func (ts *totals) periods() (all []*total) {
+	for _, t := range ts.all {
+		for len(all) > 0 {
+			next := ts.next(all[len(all)-1].Time)
+			if !next.Before(t.Time) {
+				break
+			}
+			all = append(all, &total{Time: next, Amount: coin.NewZeroAmount(t.Commodity)})
+		}
+		all = append(all, t)
+	}
+	return all
+}
+
+// average returns the mean of the amounts rounded to the commodity decimals
+func average(amounts []*total) *coin.Amount {
+	sum := new(big.Rat)
+	for _, t := range amounts {
+		sum.Add(sum, t.Rat())
+	}
+	sum.Quo(sum, new(big.Rat).SetInt64(int64(len(amounts))))
+	return amounts[0].Commodity.NewAmountRatOr(sum, coin.HalfUp)
+}
+
+// movingAverage returns a new totals sequence where each amount is
+// the average of the amount and up to n-1 preceding periods.
+func (ts *totals) movingAverage(n int) *totals {
+	avg := &totals{reducer: ts.reducer}
+	all := ts.periods()
+	i := 0
+	for _, t := range ts.all {
+		for !all[i].Equal(t.Time) {
+			i++
+		}
+		avg.add(t.Time, average(all[max(0, i-n+1):i+1]))
+	}
+	return avg
+}
+
+// statsLabels name the amounts returned by stats
+var statsLabels = []string{"Mean", "Median", "Min", "Max", "Trend"}
+
+// stats returns the mean, median, minimum, maximum and
+// the linear trend (least squares slope per period) of the totals
+// of all periods, including the periods without postings.
+func (ts *totals) stats() []*coin.Amount {
+	if len(ts.all) == 0 {
+		return nil
+	}
+	all := ts.periods()
+	c := all[0].Commodity
+	n := new(big.Rat).SetInt64(int64(len(all)))
+	sum, sumX, sumXX, sumXY := new(big.Rat), new(big.Rat), new(big.Rat), new(big.Rat)
+	for i, t := range all {
+		x := new(big.Rat).SetInt64(int64(i))
+		y := t.Rat()
+		sum.Add(sum, y)
+		sumX.Add(sumX, x)
+		sumXX.Add(sumXX, new(big.Rat).Mul(x, x))
+		sumXY.Add(sumXY, new(big.Rat).Mul(x, y))
+	}
+	sorted := append([]*total{}, all...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Int.Cmp(sorted[j].Int) < 0 })
+	mid := len(sorted) / 2
+	median := sorted[mid].Copy()
+	if len(sorted)%2 == 0 {
+		median = average(sorted[mid-1 : mid+1])
+	}
+	// slope = (n*Sxy - Sx*Sy) / (n*Sxx - Sx*Sx)
+	trend := new(big.Rat)
+	den := new(big.Rat).Sub(new(big.Rat).Mul(n, sumXX), new(big.Rat).Mul(sumX, sumX))
+	if den.Sign() != 0 {
+		trend.Sub(new(big.Rat).Mul(n, sumXY), new(big.Rat).Mul(sumX, sum))
+		trend.Quo(trend, den)
+	}
+	return []*coin.Amount{
+		average(all),
+		median,
+		sorted[0].Copy(),
+		sorted[len(sorted)-1].Copy(),
+		c.NewAmountRatOr(trend, coin.HalfUp),
+	}
+}
+
 func (ts *totals) validate(acc string) {
 	check.If(ts.current != nil, "current nil for %s", acc)
 	for _, t := range ts.all {
@@ -187,60 +272,78 @@ func (ts *totals) String() string {
 	return fmt.Sprintf("%d(%s-%s)", count, from, to)
 }
 
-type accountTotals map[*coin.Account]*totals
+// groupTotals aggregates time series amounts for groups of postings identified by keys of type K.
+// The zero value of K is reserved for the group of "Other" postings (see top).
+type groupTotals[K comparable] map[K]*totals
 
-func (ats accountTotals) String() string {
+// accountTotals aggregates time series amounts by account.
+type accountTotals = groupTotals[*coin.Account]
+
+func (ats groupTotals[K]) String() string {
 	var items []string
-	for acc, ts := range ats {
-		n := "nil"
-		if acc != nil {
-			n = acc.Name
-		}
-		items = append(items, fmt.Sprintf("%s:%s", n, ts))
+	for key, ts := range ats {
+		items = append(items, fmt.Sprintf("%v:%s", key, ts))
 	}
 	return fmt.Sprintf("totals{%s}", strings.Join(items, ", "))
 }
 
-func (ats accountTotals) newTotals(acc *coin.Account, by *reducer) *totals {
+func (ats groupTotals[K]) newTotals(acc K, by *reducer) *totals {
 	ts := &totals{reducer: by}
 	ats[acc] = ts
 	return ts
 }
 
-func (ats accountTotals) widths(order []*coin.Account) (widths []int) {
+func (ats groupTotals[K]) widths(order []K) (widths []int) {
 	for _, acc := range order {
 		widths = append(widths, ats[acc].maxWidth())
 	}
 	return widths
 }
 
-func (ats accountTotals) magnitudes() (magnitudes map[*coin.Account]*coin.Amount) {
-	magnitudes = map[*coin.Account]*coin.Amount{}
+// stats returns the summary statistics for each totals in order.
+func (ats groupTotals[K]) stats(order []K) (stats [][]*coin.Amount) {
+	for _, acc := range order {
+		stats = append(stats, ats[acc].stats())
+	}
+	return stats
+}
+
+func (ats groupTotals[K]) magnitudes() (magnitudes map[K]*coin.Amount) {
+	magnitudes = map[K]*coin.Amount{}
 	for acc, ts := range ats {
 		magnitudes[acc] = ts.cumMagnitude()
 	}
 	return magnitudes
 }
 
-func (ats accountTotals) accounts() (accounts []*coin.Account) {
-	for acc := range ats {
-		accounts = append(accounts, acc)
+func (ats groupTotals[K]) keys() (keys []K) {
+	for key := range ats {
+		keys = append(keys, key)
 	}
-	return accounts
+	return keys
 }
 
-func (ats accountTotals) makeCumulative() {
+func (ats groupTotals[K]) makeCumulative() {
 	for _, ts := range ats {
 		ts.makeCumulative()
 	}
 }
 
-// top reduces the totals to top n by maximum magnitude + others (account == nil!)
-func (ats accountTotals) top(n int) (topn accountTotals, order []*coin.Account) {
-	topn = accountTotals{}
+// sum returns totals adding up all the totals
+func (ats groupTotals[K]) sum(by *reducer) *totals {
+	sum := &totals{reducer: by}
+	for _, ts := range ats {
+		sum.merge(ts)
+	}
+	return sum
+}
+
+// top reduces the totals to top n by maximum magnitude + others (key == zero value of K!)
+func (ats groupTotals[K]) top(n int) (topn groupTotals[K], order []K) {
+	topn = groupTotals[K]{}
 	magnitudes := ats.magnitudes()
-	var accounts []*coin.Account
-	for _, acc := range ats.accounts() {
+	var accounts []K
+	for _, acc := range ats.keys() {
 		if magnitudes[acc] != nil {
 			accounts = append(accounts, acc)
 		}
@@ -257,13 +360,14 @@ func (ats accountTotals) top(n int) (topn accountTotals, order []*coin.Account)
 	}
 	rest := accounts[n:]
 	accounts = accounts[:n]
-	if len(rest) > 1 {
+	if len(rest) > 0 {
 		others := ats[rest[0]]
 		for _, acc := range rest[1:] {
 			others.merge(ats[acc])
 		}
-		topn[nil] = others
-		accounts = append(accounts, nil)
+		var other K
+		topn[other] = others
+		accounts = append(accounts, other)
 	}
 	return topn, accounts
 }
@@ -271,26 +375,22 @@ func (ats accountTotals) top(n int) (topn accountTotals, order []*coin.Account)
 // mergeTime backfills of all totals with times from ts,
 // so if ts has a union or superset of times of all the totals
 // this will align them all.
-func (ats accountTotals) mergeTime(ts *totals) {
+func (ats groupTotals[K]) mergeTime(ts *totals) {
 	for _, ts2 := range ats {
 		ts2.mergeTime(ts)
 	}
 }
 
-func (ats accountTotals) validate() {
-	for acc, ts := range ats {
-		n := "nil"
-		if acc != nil {
-			n = acc.FullName
-		}
-		ts.validate(n)
+func (ats groupTotals[K]) validate() {
+	for key, ts := range ats {
+		ts.validate(fmt.Sprint(key))
 	}
 	fmt.Println(ats)
 }
 
-// santize removes accounts that don't have any totals
-func (ats accountTotals) sanitize() {
-	var empty []*coin.Account
+// santize removes groups that don't have any totals
+func (ats groupTotals[K]) sanitize() {
+	var empty []K
 	for acc, ts := range ats {
 		if len(ts.all) == 0 {
 			empty = append(empty, acc)
@@ -301,28 +401,79 @@ func (ats accountTotals) sanitize() {
 	}
 }
 
-func (ats accountTotals) output(f io.Writer,
-	order []*coin.Account,
-	label func(*coin.Account) string,
-	format string,
+// totalsOutput configures the output of aggregated totals
+type totalsOutput struct {
+	title   string // chart title
+	format  string // output format
+	kind    string // chart kind
+	stats   bool   // append summary statistics rows
+	summary int    // number of trailing columns summarizing the others (totals, averages)
+}
+
+// output writes the totals in the specified format,
+// with summary statistics rows appended if requested.
+func (ats groupTotals[K]) output(f io.Writer,
+	order []K,
+	label func(K) string,
+	out totalsOutput,
 ) {
-	switch format {
-	case "json":
-		ats.rows(order, label).writeJSON(f)
-	case "csv":
-		ats.rows(order, label).writeCSV(f)
+	switch out.format {
+	case outText:
+		ats.print(f, order, label, out.stats)
+	case outChart, outSVG, outHTML:
+		c := ats.chart(order, label)
+		c.title, c.summary = out.title, out.summary
+		switch out.format {
+		case outSVG:
+			c.writeSVG(f, out.kind)
+		case outHTML:
+			c.writeHTML(f, out.kind)
+		default:
+			c.print(f, out.kind)
+		}
 	default:
-		ats.print(f, order, label)
+		ats.rows(order, label, out.stats).write(f, out.format)
 	}
 }
 
-func (ats accountTotals) print(f io.Writer,
-	order []*coin.Account,
-	label func(*coin.Account) string,
+// chart returns the totals as a chart with a series for each group
+func (ats groupTotals[K]) chart(order []K, label func(K) string) *chart {
+	c := &chart{}
+	firstCol := ats[order[0]]
+	for _, acc := range order {
+		c.series = append(c.series, label(acc))
+	}
+	for i := range firstCol.all {
+		c.rows = append(c.rows, firstCol.all[i].Time.Format(firstCol.format))
+		var amounts []*coin.Amount
+		for _, acc := range order {
+			amounts = append(amounts, ats[acc].all[i].Amount)
+		}
+		c.amounts = append(c.amounts, amounts)
+	}
+	return c
+}
+
+func (ats groupTotals[K]) print(f io.Writer,
+	order []K,
+	label func(K) string,
+	withStats bool,
 ) {
 	firstCol := ats[order[0]]
 	width1 := len(firstCol.all[0].Time.Format(firstCol.format))
 	widths := ats.widths(order)
+	var stats [][]*coin.Amount
+	if withStats {
+		stats = ats.stats(order)
+		for _, l := range statsLabels {
+			width1 = max(width1, len(l))
+		}
+		for i, col := range stats {
+			for _, amt := range col {
+				widths[i] = max(widths[i], amt.Width(amt.Decimals))
+			}
+		}
+	}
 	format := []string{"%*s "}
 	if label != nil {
 		labels := make([]string, len(order))
@@ -359,11 +510,23 @@ func (ats accountTotals) print(f io.Writer,
 		}
 		fmt.Fprintf(f, fmtString, args...)
 	}
+	for i, l := range statsLabels {
+		if stats == nil {
+			break
+		}
+		args := []interface{}{width1, l}
+		for ii := range order {
+			args = append(args, widths[ii])
+			args = append(args, stats[ii][i])
+		}
+		fmt.Fprintf(f, fmtString, args...)
+	}
 }
 
-func (ats accountTotals) rows(
-	order []*coin.Account,
-	label func(*coin.Account) string,
+func (ats groupTotals[K]) rows(
+	order []K,
+	label func(K) string,
+	withStats bool,
 ) (rs rows) {
 	header := []string{"Date"}
 	for _, acc := range order {
@@ -382,30 +545,24 @@ func (ats accountTotals) rows(
 		}
 		rs = append(rs, row)
 	}
-	return rs
-}
-
-type rows [][]string
-
-func (rs rows) writeCSV(f io.Writer) {
-	w := csv.NewWriter(f)
-	for _, r := range rs {
-		w.Write(r)
-	}
-	w.Flush()
-}
-
-func (rs rows) writeJSON(f io.Writer) {
-	w := json.NewEncoder(f)
-	for _, r := range rs {
-		w.Encode(r)
+	if withStats {
+		stats := ats.stats(order)
+		for i, l := range statsLabels {
+			row := []string{l}
+			for ii := range order {
+				row = append(row, stats[ii][i].String())
+			}
+			rs = append(rs, row)
+		}
 	}
+	return rs
 }
 
 // reducer coerces time to specified period
 // and carries corresponding time format string.
 type reducer struct {
 	reduce func(t time.Time) time.Time
+	next   func(t time.Time) time.Time // start of the following period
 	format string
 }
 
@@ -416,6 +573,9 @@ var week = reducer{
 		y, m, d := t.Date()
 		return time.Date(y, m, d, 12, 0, 0, 0, time.UTC)
 	},
+	next: func(t time.Time) time.Time {
+		return t.AddDate(0, 0, 7)
+	},
 	format: coin.DateFormat,
 }
 
@@ -424,6 +584,9 @@ var month = reducer{
 		y, m, _ := t.Date()
 		return time.Date(y, m, 1, 12, 0, 0, 0, time.UTC)
 	},
+	next: func(t time.Time) time.Time {
+		return t.AddDate(0, 1, 0)
+	},
 	format: coin.MonthFormat,
 }
 
@@ -433,6 +596,9 @@ var quarter = reducer{
 		m = ((m - 1) / 3 * 3) + 1
 		return time.Date(y, m, 1, 12, 0, 0, 0, time.UTC)
 	},
+	next: func(t time.Time) time.Time {
+		return t.AddDate(0, 3, 0)
+	},
 	format: coin.MonthFormat,
 }
 
@@ -441,5 +607,8 @@ var year = reducer{
 		y, _, _ := t.Date()
 		return time.Date(y, time.January, 1, 12, 0, 0, 0, time.UTC)
 	},
+	next: func(t time.Time) time.Time {
+		return t.AddDate(1, 0, 0)
+	},
 	format: coin.YearFormat,
 }
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/mkobetic/coin"
+	"github.com/mkobetic/coin/assert"
+)
+
+func Test_StatsRoundHalfUp(t *testing.T) {
+	cad := &coin.Commodity{Id: "CAD", Decimals: 2}
+	for _, tc := range []struct {
+		values []int64 // monthly totals in cents
+		stats  string  // mean, median, min, max, trend
+	}{
+		// median 0.015 and trend 0.01
+		{[]int64{1, 2}, "0.02 0.02 0.01 0.02 0.01"},
+		// mean 0.0133, median 0.01, trend 0.005
+		{[]int64{1, 1, 2}, "0.01 0.01 0.01 0.02 0.01"},
+		// mean -0.015, median -0.015, trend -0.01
+		{[]int64{-1, -2}, "-0.02 -0.02 -0.02 -0.01 -0.01"},
+	} {
+		ts := &totals{reducer: &month}
+		for i, v := range tc.values {
+			ts.add(coin.MustParseDate("2010/01").AddDate(0, i, 0), coin.NewAmount(big.NewInt(v), cad))
+		}
+		var stats []string
+		for _, a := range ts.stats() {
+			stats = append(stats, a.String())
+		}
+		assert.EqualStrings(t, stats, strings.Fields(tc.stats)...)
+	}
+}
@@ -2,28 +2,19 @@ package main
 
 import (
 	"bufio"
-	"compress/gzip"
-	"encoding/base64"
-	"io"
 	"sort"
 	"strings"
 
 	"github.com/mkobetic/coin"
-	"github.com/mkobetic/coin/check"
 )
 
-// These are used for embedding the report files.
-// see embed.go and reports.go
-
-func decode(name, encoded string) (decoded []byte) {
-	r, err := gzip.NewReader(base64.NewDecoder(base64.StdEncoding, strings.NewReader(encoded)))
-	check.NoError(err, "opening %s", name)
-	decoded, err = io.ReadAll(r)
-	check.NoError(err, "reading %s", name)
-	return decoded
-}
-
-func trim(ps []*coin.Posting, begin, end coin.Date) []*coin.Posting {
+// trim returns the postings dated in the range [begin, end) ordered by date,
+// using the effective dates of the postings if effective is set.
+// The postings are expected to be sorted by their posted dates.
+func trim(ps []*coin.Posting, begin, end coin.Date, effective bool) []*coin.Posting {
+	if effective {
+		return trimEffective(ps, begin, end)
+	}
 	if !begin.IsZero() {
 		from := sort.Search(len(ps), func(i int) bool {
 			return !ps[i].Transaction.Posted.Before(begin.Time)
@@ -45,6 +36,22 @@ func trim(ps []*coin.Posting, begin, end coin.Date) []*coin.Posting {
 	return ps
 }
 
+// trimEffective filters the postings by their effective dates
+// which are not ordered, so the result needs to be sorted again.
+func trimEffective(ps []*coin.Posting, begin, end coin.Date) (trimmed []*coin.Posting) {
+	for _, p := range ps {
+		d := p.Date(true)
+		if !begin.IsZero() && d.Before(begin.Time) || !end.IsZero() && !d.Before(end.Time) {
+			continue
+		}
+		trimmed = append(trimmed, p)
+	}
+	sort.SliceStable(trimmed, func(i, j int) bool {
+		return trimmed[i].Time(true).Before(trimmed[j].Time(true))
+	})
+	return trimmed
+}
+
 func trimWS(in ...string) (out []string) {
 	for _, line := range in {
 		var w strings.Builder
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"strings"
 	"testing"
 
+	"github.com/mkobetic/coin"
 	"github.com/mkobetic/coin/assert"
 )
 
@@ -16,3 +18,21 @@ func Test_TrimWS(t *testing.T) {
 		assert.EqualStrings(t, trimWS(tc.in...), tc.out...)
 	}
 }
+
+func Test_LocalizedRows(t *testing.T) {
+	defer func(l *coin.Locale) { coin.Output = l }(coin.Output)
+	coin.Output = coin.MustParseLocale("date=02.01.2006 decimal=, thousands=.")
+	rs := rows{
+		{"Date", "Account", "Amount", "Days"},
+		{"2024/01/05", "Assets:Bank", "-1234.50", "31"},
+	}
+	var b strings.Builder
+	rs.write(&b, outCSV)
+	assert.Equal(t, b.String(), "Date;Account;Amount;Days\n05.01.2024;Assets:Bank;-1.234,50;31\n")
+	b.Reset()
+	rs.write(&b, outText)
+	assert.Equal(t, b.String(), "Date       | Account     |    Amount | Days\n05.01.2024 | Assets:Bank | -1.234,50 |   31\n")
+	b.Reset()
+	rs.write(&b, outJSON)
+	assert.Equal(t, b.String(), `["Date","Account","Amount","Days"]`+"\n"+`["2024/01/05","Assets:Bank","-1234.50","31"]`+"\n")
+}
@@ -14,6 +14,7 @@ import (
 
 	"github.com/mkobetic/coin"
 	"github.com/mkobetic/coin/check"
+	"github.com/mkobetic/coin/mapping"
 )
 
 const usage = `Usage: csv2coin [flags] files...
@@ -57,9 +58,9 @@ func main() {
 		return
 	}
 
-	var src *Source
+	var src *mapping.Source
 	if *fields != "" {
-		src = &Source{fields: parseFields(*fields)}
+		src = &mapping.Source{Fields: parseFields(*fields)}
 	} else if *source != "" {
 		src = rules.sources[*source]
 		check.If(src != nil, "Unknown source %s", *source)
@@ -87,9 +88,9 @@ func main() {
 	}
 }
 
-func readTransactions(in io.Reader, src *Source, rules *Rules) (transactions []*coin.Transaction) {
+func readTransactions(in io.Reader, src *mapping.Source, rules *Rules) (transactions []*coin.Transaction) {
 	r := csv.NewReader(in)
-	for i := 0; i < src.skip; i++ {
+	for i := 0; i < src.Skip; i++ {
 		row, err := r.Read()
 		if err, ok := err.(*csv.ParseError); ok && err.Err == csv.ErrFieldCount {
 			r.FieldsPerRecord = len(row)
@@ -103,7 +104,7 @@ func readTransactions(in io.Reader, src *Source, rules *Rules) (transactions []*
 			break
 		}
 		check.NoError(err, "reading transaction")
-		if nt := transactionFrom(rec, src.fields, rules); nt != nil {
+		if nt := transactionFrom(rec, src.Fields, rules); nt != nil {
 			transactions = append(transactions, nt)
 		}
 	}
@@ -111,7 +112,7 @@ func readTransactions(in io.Reader, src *Source, rules *Rules) (transactions []*
 }
 
 // transactionFrom builds a transaction from a csv row.
-func transactionFrom(row []string, fields map[string]Fields, allRules *Rules) *coin.Transaction {
+func transactionFrom(row []string, fields map[string]mapping.Fields, allRules *Rules) *coin.Transaction {
 	valueFor := func(name string) string {
 		check.Includes(labels, name, "Invalid field name")
 		return fields[name].Value(row, fields)
@@ -220,15 +221,15 @@ var labels = []string{
 	"note",        // optional note associated with the transaction
 }
 
-func parseFields(list string) map[string]Fields {
+func parseFields(list string) map[string]mapping.Fields {
 	idxs := strings.Split(list, ",")
 	check.If(len(idxs) == len(labels),
 		"%d fields must be specified:\n%v\n", len(labels), labels)
-	fields := make(map[string]Fields)
+	fields := make(map[string]mapping.Fields)
 	for i, s := range idxs {
 		c, err := strconv.Atoi(s)
 		check.NoError(err, "%s is not a valid column index", i)
-		fields[labels[i]] = Fields{&Field{&c, "", "", nil}}
+		fields[labels[i]] = mapping.Fields{mapping.Column(c)}
 	}
 	return fields
 }
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mkobetic/coin"
+	"github.com/mkobetic/coin/check"
+	"github.com/mkobetic/coin/mapping"
+)
+
+const usage = `Usage: prices2coin [flags] files...
+
+Converts CSV or XML files with price or exchange rate histories to coin prices
+based on a source mapping (see README). Prices already in the ledger are skipped.
+
+Flags:`
+
+var (
+	fields    = flag.String("fields", "", "ordered list of column indexes to use as price fields")
+	source    = flag.String("source", "", "which source mapping to use to read the files")
+	dumpRules = flag.Bool("rules", false, "dump the loaded source mappings (useful for formatting)")
+)
+
+func init() {
+	flag.Usage = func() {
+		w := flag.CommandLine.Output()
+		fmt.Fprintln(w, usage)
+		flag.PrintDefaults()
+	}
+}
+
+func main() {
+	flag.Parse()
+
+	coin.LoadPrices()
+	coin.ResolvePrices()
+
+	sources := map[string]*mapping.Source{}
+	fn := filepath.Join(coin.DB, "prices.rules")
+	if _, err := os.Stat(fn); !os.IsNotExist(err) {
+		file, err := os.Open(fn)
+		check.NoError(err, "Failed to open %s", fn)
+		defer file.Close()
+		sources = ReadRules(file)
+	}
+
+	if *dumpRules {
+		for _, src := range sources {
+			src.Write(os.Stdout, labels)
+		}
+		return
+	}
+
+	var src *mapping.Source
+	if *fields != "" {
+		src = &mapping.Source{Fields: parseFields(*fields)}
+	} else if *source != "" {
+		src = sources[*source]
+		check.If(src != nil, "Unknown source %s\n", *source)
+	} else {
+		fmt.Fprintf(os.Stderr, "One of -source or -fields must be specified\n")
+		os.Exit(1)
+	}
+
+	var prices []*coin.Price
+	for _, fileName := range flag.Args() {
+		file, err := os.Open(fileName)
+		check.NoError(err, "Failed to open %s", fileName)
+		defer file.Close()
+
+		var rows [][]string
+		if strings.EqualFold(filepath.Ext(fileName), ".xml") {
+			rows, err = readXMLRows(file)
+		} else {
+			rows, err = readCSVRows(file)
+		}
+		check.NoError(err, "Failed to read %s", fileName)
+		prices = append(prices, readPrices(rows, src)...)
+	}
+
+	for _, p := range newPrices(prices) {
+		p.Write(os.Stdout, false)
+	}
+}
+
+// readCSVRows reads all CSV records, records can have varying number of fields.
+func readCSVRows(in io.Reader) ([][]string, error) {
+	r := csv.NewReader(in)
+	r.FieldsPerRecord = -1
+	return r.ReadAll()
+}
+
+// readXMLRows flattens XML into rows. Each element without child elements yields a row
+// of the attribute values of its ancestors followed by its own attribute values and text
+// (if any) in document order, namespace declarations are ignored. For example the ECB rates
+//
+//	<Cube time="2024-01-05"><Cube currency="USD" rate="1.0921"/></Cube>
+//
+// yield row 2024-01-05,USD,1.0921
+func readXMLRows(in io.Reader) (rows [][]string, err error) {
+	type element struct {
+		values   []string // inherited and own values
+		text     strings.Builder
+		children bool
+	}
+	stack := []*element{{}}
+	d := xml.NewDecoder(in)
+	for {
+		token, err := d.Token()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			parent := stack[len(stack)-1]
+			parent.children = true
+			e := &element{values: append([]string{}, parent.values...)}
+			for _, a := range t.Attr {
+				if a.Name.Space == "xmlns" || a.Name.Space == "" && a.Name.Local == "xmlns" {
+					continue // namespace declaration
+				}
+				e.values = append(e.values, a.Value)
+			}
+			stack = append(stack, e)
+		case xml.CharData:
+			stack[len(stack)-1].text.Write(t)
+		case xml.EndElement:
+			e := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if e.children {
+				continue
+			}
+			row := e.values
+			if text := strings.TrimSpace(e.text.String()); text != "" {
+				row = append(row, text)
+			}
+			if len(row) > 0 {
+				rows = append(rows, row)
+			}
+		}
+	}
+}
+
+// readPrices converts rows to prices, the header rows are skipped
+// as well as rows without enough columns and rows that don't map
+// to existing commodities.
+func readPrices(rows [][]string, src *mapping.Source) (prices []*coin.Price) {
+	columns := src.Columns()
+	unknown := map[string]bool{}
+	for i, row := range rows {
+		if i < src.Skip || len(row) < columns {
+			continue
+		}
+		p, err := priceFrom(row, src.Fields)
+		if err != nil {
+			if u, ok := err.(unknownCommodity); ok {
+				if !unknown[string(u)] {
+					fmt.Fprintf(os.Stderr, "skipping unknown commodity %s\n", string(u))
+				}
+				unknown[string(u)] = true
+				continue
+			}
+			check.NoError(err, "row %d: %s", i+1, strings.Join(row, ","))
+		}
+		prices = append(prices, p)
+	}
+	return prices
+}
+
+type unknownCommodity string
+
+func (u unknownCommodity) Error() string { return "unknown commodity " + string(u) }
+
+// priceFrom builds a price from a row. The price of the default commodity
+// is inverted into the price of the currency in the default commodity,
+// as is any price with non-empty inverse value.
+func priceFrom(row []string, fields map[string]mapping.Fields) (*coin.Price, error) {
+	valueFor := func(name string) string {
+		check.Includes(labels, name, "Invalid field name")
+		return strings.TrimSpace(fields[name].Value(row, fields))
+	}
+	commodity, err := findCommodity(valueFor("symbol"))
+	if err != nil {
+		return nil, err
+	}
+	currency, err := findCommodity(valueFor("currency"))
+	if err != nil {
+		return nil, err
+	}
+	date := valueFor("date")
+	posted, err := parseDate(date)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date %s", date)
+	}
+	value, err := parsePrice(valueFor("price"))
+	if err != nil {
+		return nil, err
+	}
+	if commodity.Id == coin.DefaultCommodityId || valueFor("inverse") != "" {
+		commodity, currency = currency, commodity
+		value.Inv(value)
+	}
+	return coin.NewPrice(commodity, currency, posted, value), nil
+}
+
+// priceREX matches decimal prices, optionally with thousands separators (e.g. 1,234.56),
+// a decimal comma (e.g. 1,0921) has to be converted with the source mapping.
+var priceREX = regexp.MustCompile(`^(\d{1,3}(,\d{3})+|\d+)(\.\d+)?$`)
+
+func parsePrice(s string) (*big.Rat, error) {
+	if !priceREX.MatchString(s) {
+		return nil, fmt.Errorf("invalid price %s", s)
+	}
+	value, ok := new(big.Rat).SetString(strings.ReplaceAll(s, ",", ""))
+	if !ok || value.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid price %s", s)
+	}
+	return value, nil
+}
+
+func findCommodity(id string) (*coin.Commodity, error) {
+	if c := coin.Commodities[id]; c != nil {
+		return c, nil
+	}
+	if c := coin.CommoditiesBySymbol[id]; c != nil {
+		return c, nil
+	}
+	return nil, unknownCommodity(id)
+}
+
+// parseDate parses dates as YYYY/MM/DD or YYYY-MM-DD
+func parseDate(s string) (time.Time, error) {
+	d, err := time.Parse(coin.DateFormat, s)
+	if err != nil {
+		d, err = time.Parse("2006-01-02", s)
+	}
+	// same time of day as the dates parsed from the ledger
+	return d.Add(12 * time.Hour), err
+}
+
+// newPrices returns the prices that are not in the ledger
+// (the same commodity, currency and date) ordered by date.
+func newPrices(prices []*coin.Price) (fresh []*coin.Price) {
+	key := func(p *coin.Price) string {
+		return p.CommodityId + " " + p.Value.Commodity.Id + " " + p.Time.Format(coin.DateFormat)
+	}
+	seen := map[string]bool{}
+	for _, p := range coin.Prices {
+		seen[key(p)] = true
+	}
+	for _, p := range prices {
+		if !seen[key(p)] {
+			seen[key(p)] = true
+			fresh = append(fresh, p)
+		}
+	}
+	sort.SliceStable(fresh, func(i, j int) bool {
+		return fresh[i].Time.Before(fresh[j].Time)
+	})
+	return fresh
+}
+
+var labels = []string{
+	"date",     // date of the price
+	"symbol",   // symbol of the commodity that is priced
+	"price",    // the price of one unit of the commodity
+	"currency", // symbol of the currency of the price
+	"inverse",  // optional, if not empty the price is the price of the currency in the commodity
+}
+
+func parseFields(list string) map[string]mapping.Fields {
+	idxs := strings.Split(list, ",")
+	check.If(len(idxs) == len(labels)-1,
+		"%d fields must be specified:\n%v\n", len(labels)-1, labels[:len(labels)-1])
+	fields := make(map[string]mapping.Fields)
+	for i, s := range idxs {
+		c, err := strconv.Atoi(s)
+		check.NoError(err, "%s is not a valid column index", s)
+		fields[labels[i]] = mapping.Fields{mapping.Column(c)}
+	}
+	return fields
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mkobetic/coin"
+	"github.com/mkobetic/coin/assert"
+	"github.com/mkobetic/coin/mapping"
+)
+
+var sources map[string]*mapping.Source
+
+func init() {
+	coin.DefaultCommodityId = "CAD"
+
+	r := strings.NewReader(`
+commodity CAD
+commodity EUR
+commodity USD
+  format 1.0000 USD
+commodity VGRO
+  symbol VGRO.TO
+
+P 2024/01/04 VGRO 30.10 CAD
+`)
+	coin.Load(r, "")
+	coin.ResolvePrices()
+
+	sources = ReadRules(strings.NewReader(`# ECB rates
+ecb 0
+  date 0
+  symbol "EUR"
+  currency 1
+  price 2
+
+broker 1
+  date 0 "$3/$1/$2" (\d\d)/(\d\d)/(\d\d\d\d)
+  symbol 1
+  price 2
+  currency "CAD"
+
+fx 1
+  date 0
+  symbol 1
+  currency 2
+  price 3
+  inverse "yes" symbol USD
+`))
+}
+
+func Test_ReadRules(t *testing.T) {
+	assert.Equal(t, len(sources), 3)
+	assert.Equal(t, sources["ecb"].Columns(), 3)
+	assert.Equal(t, sources["broker"].Skip, 1)
+	assert.Equal(t, sources["fx"].Value("inverse", []string{"2024-01-05", "USD", "EUR", "0.9157"}), "yes")
+	assert.Equal(t, sources["fx"].Value("inverse", []string{"2024-01-05", "EUR", "USD", "1.0921"}), "")
+
+	var b strings.Builder
+	sources["broker"].Write(&b, labels)
+	sources["fx"].Write(&b, labels)
+	assert.Equal(t, b.String(), `broker 1
+  date 0 "$3/$1/$2" (\d\d)/(\d\d)/(\d\d\d\d)
+  symbol 1
+  price 2
+  currency "CAD"
+fx 1
+  date 0
+  symbol 1
+  price 3
+  currency 2
+  inverse "yes" symbol USD
+`)
+}
+
+func Test_XMLPrices(t *testing.T) {
+	rows, err := readXMLRows(strings.NewReader(`<?xml version="1.0" encoding="UTF-8"?>
+<gesmes:Envelope xmlns:gesmes="http://www.gesmes.org/xml/2002-08-01" xmlns="http://www.ecb.int/vocabulary/2002-08-01/eurofxref">
+	<gesmes:subject>Reference rates</gesmes:subject>
+	<Cube>
+		<Cube time="2024-01-05">
+			<Cube currency="USD" rate="1.0921"/>
+			<Cube currency="CAD" rate="1.4600"/>
+			<Cube currency="JPY" rate="158.41"/>
+		</Cube>
+	</Cube>
+</gesmes:Envelope>`))
+	assert.NoError(t, err)
+	assert.Equal(t, len(rows), 4)
+	assert.EqualStrings(t, rows[0], "Reference rates")
+	assert.EqualStrings(t, rows[1], "2024-01-05", "USD", "1.0921")
+
+	prices := readPrices(rows, sources["ecb"])
+	assert.Equal(t, len(prices), 2)
+	assert.Equal(t, prices[0].String(), "P 2024/01/05 EUR 1.0921 USD\n")
+	assert.Equal(t, prices[1].String(), "P 2024/01/05 EUR 1.46 CAD\n")
+}
+
+func Test_CSVPrices(t *testing.T) {
+	rows, err := readCSVRows(strings.NewReader(`Date,Symbol,Close
+01/04/2024,VGRO.TO,30.10
+01/05/2024,VGRO.TO,30.52
+01/05/2024,VGRO.TO,30.52
+`))
+	assert.NoError(t, err)
+	prices := newPrices(readPrices(rows, sources["broker"]))
+	assert.Equal(t, len(prices), 1)
+	assert.Equal(t, prices[0].String(), "P 2024/01/05 VGRO 30.52 CAD\n")
+
+	rows, err = readCSVRows(strings.NewReader(`date,base,quote,rate
+2024-01-05,USD,EUR,0.9157
+2024-01-05,CAD,USD,0.7492
+`))
+	assert.NoError(t, err)
+	prices = readPrices(rows, sources["fx"])
+	assert.Equal(t, len(prices), 2)
+	assert.Equal(t, prices[0].String(), "P 2024/01/05 EUR 1.092060718576 USD\n")
+	assert.Equal(t, prices[1].String(), "P 2024/01/05 USD 1.3347570742 CAD\n")
+}
+
+func Test_ParsePrice(t *testing.T) {
+	for i, fix := range []struct{ in, out string }{
+		{"1.0921", "1.0921"},
+		{"1,234.5", "1234.5000"},
+		{"1234", "1234.0000"},
+	} {
+		value, err := parsePrice(fix.in)
+		assert.NoError(t, err)
+		assert.Equal(t, value.FloatString(4), fix.out, "%d. not equal", i)
+	}
+	// decimal commas are rejected rather than read as thousands separators
+	for _, s := range []string{"1,0921", "12,34.5", "-1.5", "0", ""} {
+		_, err := parsePrice(s)
+		assert.True(t, err != nil, "%s should fail", s)
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+
+	"github.com/mkobetic/coin/check"
+	"github.com/mkobetic/coin/mapping"
+)
+
+// ReadRules reads the source definitions of a prices.rules file,
+// blank lines and lines starting with ; or # are ignored.
+func ReadRules(r io.Reader) map[string]*mapping.Source {
+	var lines [][]byte
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := bytes.TrimRight(s.Bytes(), " \t")
+		if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 && trimmed[0] != ';' && trimmed[0] != '#' {
+			lines = append(lines, append([]byte{}, line...))
+		}
+	}
+	check.NoError(s.Err(), "Failed reading rules")
+	sources := map[string]*mapping.Source{}
+	s = bufio.NewScanner(bytes.NewReader(bytes.Join(lines, []byte("\n"))))
+	if !s.Scan() {
+		return sources
+	}
+	// ScanSource leaves the scanner on the line following the source, empty at the end
+	for line := s.Bytes(); len(line) > 0; line = s.Bytes() {
+		source := mapping.ScanSource(line, s)
+		check.If(source != nil, "invalid source definition in rules file: %s\n", string(line))
+		sources[source.Name] = source
+	}
+	return sources
+}
@@ -114,6 +114,8 @@ func Load(r io.Reader, fn string) {
 			AccountsByName[i.FullName] = i
 		case *Price:
 			Prices = append(Prices, i)
+		case *Event:
+			Events = append(Events, i)
 		case *Transaction:
 			Transactions = append(Transactions, i)
 		case *Test:
@@ -135,9 +137,11 @@ func Load(r io.Reader, fn string) {
 }
 
 func ResolveAll() {
+	ResolveEvents()
 	ResolvePrices()
 	ResolveAccounts()
 	ResolveTransactions(true)
+	ResolveImplicitPrices()
 }
 
 func ResolvePrices() {
@@ -149,12 +153,12 @@ func ResolvePrices() {
 	// Sort commodity prices.
 	for _, c := range Commodities {
 		for _, p := range c.Prices {
-			sort.Slice(p, func(i, j int) bool {
+			sort.SliceStable(p, func(i, j int) bool {
 				return p[i].Time.After(p[j].Time)
 			})
 		}
 	}
-	sort.Slice(Prices, func(i, j int) bool {
+	sort.SliceStable(Prices, func(i, j int) bool {
 		return Prices[i].Time.Before(Prices[j].Time)
 	})
 }
@@ -6,8 +6,10 @@ import (
 	"fmt"
 	"io"
 	"math/big"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 	"unicode"
 
 	"github.com/mkobetic/coin/rex"
@@ -20,15 +22,22 @@ var (
 )
 
 type Commodity struct {
-	Id       string
-	Name     string
-	Code     string
-	Decimals int    // how many decimal places to use
-	NoMarket bool   // Don't download prices
-	Symbol   string // symbol to use for quotes
+	Id        string
+	Name      string
+	Code      string
+	Decimals  int    // how many decimal places to use
+	Thousands bool   // print thousands separators, e.g. format 1,000.00 USD
+	NoMarket  bool   // Don't download prices
+	Symbol    string // symbol to use for quotes
+	Class     string // asset class for allocation reports, e.g. equity, bond, cash
+	Quote     string // quote provider and its arguments, e.g. yahoo
+	Rounding  string // rounding mode of amounts computed from exact values, e.g. conversions (default truncate)
 
 	// price lists by currency
 	Prices map[*Commodity][]*Price
+	// price lists derived from transactions by currency,
+	// used only when there is no conversion path through explicit prices
+	ImplicitPrices map[*Commodity][]*Price
 
 	// Id quoted if required by ledger
 	quotedId string
@@ -44,6 +53,13 @@ func (c *Commodity) AddPrice(p *Price) {
 	c.Prices[p.Currency] = append(c.Prices[p.Currency], p)
 }
 
+func (c *Commodity) addImplicitPrice(p *Price) {
+	if c.ImplicitPrices == nil {
+		c.ImplicitPrices = make(map[*Commodity][]*Price)
+	}
+	c.ImplicitPrices[p.Currency] = append(c.ImplicitPrices[p.Currency], p)
+}
+
 func (c *Commodity) Currencies() (currencies []*Commodity) {
 	for cur := range c.Prices {
 		currencies = append(currencies, cur)
@@ -105,14 +121,20 @@ func (c *Commodity) Location() string {
 commodity USD
 
 	note American Dollars
-	format 1000.00 USD
+	format 1,000.00 USD
 	nomarket
+	class cash
+	quote yahoo
+	round half-even
 	default
 */
 func (c *Commodity) Write(w io.Writer, ledger bool) error {
 	format := "1"
+	if c.Thousands {
+		format = "1,000"
+	}
 	if c.Decimals > 0 {
-		format = "1." + strings.Repeat("0", c.Decimals)
+		format += "." + strings.Repeat("0", c.Decimals)
 	}
 	lines := []string{"commodity ", c.SafeId(ledger), "\n"}
 	if c.Name != "" {
@@ -122,6 +144,15 @@ func (c *Commodity) Write(w io.Writer, ledger bool) error {
 	if c.NoMarket {
 		lines = append(lines, "  nomarket\n")
 	}
+	if c.Class != "" {
+		lines = append(lines, "  class ", c.Class, "\n")
+	}
+	if c.Quote != "" {
+		lines = append(lines, "  quote ", c.Quote, "\n")
+	}
+	if c.Rounding != "" {
+		lines = append(lines, "  round ", c.Rounding, "\n")
+	}
 	for _, line := range lines {
 		_, err := io.WriteString(w, line)
 		if err != nil {
@@ -138,6 +169,9 @@ var commodityBodyREX = rex.MustCompile(``+
 	`(\s+format\s+(?P<format>%s))|`+
 	`(\s+(?P<nomarket>nomarket)\s*)|`+
 	`(\s+symbol\s+(?P<symbol>[\w\.]+))|`+
+	`(\s+class\s+(?P<class>\w+))|`+
+	`(\s+quote\s+(?P<quote>\S.*?)\s*$)|`+
+	`(\s+round\s+(?P<round>truncate|half-even|half-up)\s*$)|`+
 	`(\s+(?P<default>default)\s*)`,
 	AmountREX)
 
@@ -162,10 +196,17 @@ func (p *Parser) parseCommodity(fn string) (*Commodity, error) {
 			} else {
 				c.Decimals = len(f) - 1
 			}
+			c.Thousands = strings.Contains(match["amount"], ",")
 		} else if match["nomarket"] != "" {
 			c.NoMarket = true
 		} else if s := match["symbol"]; s != "" {
 			c.Symbol = s
+		} else if cl := match["class"]; cl != "" {
+			c.Class = cl
+		} else if q := match["quote"]; q != "" {
+			c.Quote = q
+		} else if r := match["round"]; r != "" {
+			c.Rounding = r
 		} else if match["default"] != "" {
 			DefaultCommodityId = c.Id
 		} else {
@@ -179,7 +220,47 @@ func (p *Parser) parseCommodity(fn string) (*Commodity, error) {
 // commodity prices. Try to find a conversion path through known intermediate
 // commodities as well.
 func (c *Commodity) Convert(amount *Amount, c2 *Commodity) (*Amount, error) {
-	return c.convert(amount, c2, nil)
+	return c.convertTo(amount, c2, latestPrice)
+}
+
+// ConvertAt converts the amount from c2 commodity to amount in c commodity
+// using the latest commodity prices posted before time t.
+func (c *Commodity) ConvertAt(amount *Amount, c2 *Commodity, t time.Time) (*Amount, error) {
+	return c.convertTo(amount, c2, func(prices []*Price) *Price {
+		return priceBefore(prices, t)
+	})
+}
+
+// convertTo converts the amount using prices selected by the price function
+// and returns it in c commodity. The conversion is exact,
+// the result is rounded using the rounding mode of c.
+func (c *Commodity) convertTo(amount *Amount, c2 *Commodity, price func([]*Price) *Price) (*Amount, error) {
+	converted, err := c.convert(amount.Rat(), c2, nil, price, false)
+	if err != nil {
+		// implicit prices are used only if there is no explicit conversion path
+		converted, err = c.convert(amount.Rat(), c2, nil, price, true)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c.NewAmountRat(converted), nil
+}
+
+// latestPrice returns the latest price from prices sorted from the latest
+func latestPrice(prices []*Price) *Price {
+	return prices[0]
+}
+
+// priceBefore returns the latest price from prices (sorted from the latest)
+// that was posted before time t, nil if there isn't one.
+func priceBefore(prices []*Price, t time.Time) *Price {
+	i := sort.Search(len(prices), func(i int) bool {
+		return prices[i].Time.Before(t)
+	})
+	if i == len(prices) {
+		return nil
+	}
+	return prices[i]
 }
 
 func (c *Commodity) includedIn(list []*Commodity) bool {
@@ -191,27 +272,56 @@ func (c *Commodity) includedIn(list []*Commodity) bool {
 	return false
 }
 
-func (c *Commodity) convert(amount *Amount, c2 *Commodity, previous []*Commodity) (*Amount, error) {
+// priceIn returns the price of c in currency cur selected by the price function,
+// if implicit is set the implicit prices are used when there is no explicit price.
+func (c *Commodity) priceIn(cur *Commodity, implicit bool, price func([]*Price) *Price) *Price {
+	if prices := c.Prices[cur]; len(prices) > 0 {
+		if p := price(prices); p != nil {
+			return p
+		}
+	}
+	if prices := c.ImplicitPrices[cur]; implicit && len(prices) > 0 {
+		return price(prices)
+	}
+	return nil
+}
+
+// priceCurrencies returns the currencies c has prices in,
+// including the currencies of implicit prices if implicit is set.
+func (c *Commodity) priceCurrencies(implicit bool) map[*Commodity]bool {
+	currencies := map[*Commodity]bool{}
+	for cur := range c.Prices {
+		currencies[cur] = true
+	}
+	if implicit {
+		for cur := range c.ImplicitPrices {
+			currencies[cur] = true
+		}
+	}
+	return currencies
+}
+
+func (c *Commodity) convert(amount *big.Rat, c2 *Commodity, previous []*Commodity, price func([]*Price) *Price, implicit bool) (*big.Rat, error) {
 	if c == c2 {
 		// Nothing to convert
 		return amount, nil
 	}
 	// Does c2 have prices in c currency?
-	prices := c2.Prices[c]
-	if prices != nil {
-		p := prices[0]
-		val := amount.Times(p.Value)
-		return val, nil
+	if p := c2.priceIn(c, implicit, price); p != nil {
+		return new(big.Rat).Mul(amount, p.ExactRate()), nil
 	}
 	// Otherwise try to follow each c2 price currency
-	for c3, prices := range c2.Prices {
+	for c3 := range c2.priceCurrencies(implicit) {
 		// Check if we tried this currency before to avoid cycles
 		if c3.includedIn(previous) {
 			continue
 		}
-		p := prices[0]
-		val2 := amount.Times(p.Value)
-		val3, err := c.convert(val2, c3, append(previous, c2))
+		p := c2.priceIn(c3, implicit, price)
+		if p == nil {
+			continue
+		}
+		val2 := new(big.Rat).Mul(amount, p.ExactRate())
+		val3, err := c.convert(val2, c3, append(previous, c2), price, implicit)
 		if err == nil {
 			return val3, nil
 		}
@@ -220,6 +330,21 @@ func (c *Commodity) convert(amount *Amount, c2 *Commodity, previous []*Commodity
 	return nil, fmt.Errorf("cannot convert %s => %s", c2.Id, c.Id)
 }
 
+// NewAmountRat returns the value as an amount of the commodity
+// rounded using the rounding mode of the commodity.
+func (c *Commodity) NewAmountRat(r *big.Rat) *Amount {
+	return NewAmount(round(r, c.Decimals, c.Rounding), c)
+}
+
+// NewAmountRatOr returns the value as an amount of the commodity
+// rounded using the rounding mode of the commodity, or mode if it doesn't have one.
+func (c *Commodity) NewAmountRatOr(r *big.Rat, mode string) *Amount {
+	if c.Rounding != "" {
+		mode = c.Rounding
+	}
+	return NewAmount(round(r, c.Decimals, mode), c)
+}
+
 func (c *Commodity) NewAmountFloat(f float64) *Amount {
 	return NewAmount(
 		// FIXME: the int64 conversion can overflow
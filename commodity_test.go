@@ -1,6 +1,8 @@
 package coin
 
 import (
+	"fmt"
+	"math/big"
 	"strings"
 	"testing"
 
@@ -11,11 +13,14 @@ func Test_ParseCommodity(t *testing.T) {
 	r := strings.NewReader(`
 commodity NBC814
   note Altamira Precision Canadian Index Fund
-  format 1.0000 NBC814
+  format 1,000.0000 NBC814
 
 commodity BND
   note Vanguard Total Bond Market ETF
   format 1 BND
+  class bond
+  round half-even
+  quote json https://example.com/{symbol} data.0.price USD
 `)
 	p := NewParser(r)
 	i, err := p.Next("")
@@ -25,6 +30,10 @@ commodity BND
 	assert.Equal(t, c.Id, "NBC814")
 	assert.Equal(t, c.Name, "Altamira Precision Canadian Index Fund")
 	assert.Equal(t, c.Decimals, 4)
+	assert.Equal(t, c.Thousands, true)
+	var b strings.Builder
+	assert.NoError(t, c.Write(&b, false))
+	assert.Equal(t, strings.Split(b.String(), "\n")[2], "  format 1,000.0000 NBC814")
 
 	i, err = p.Next("")
 	assert.NoError(t, err)
@@ -33,4 +42,69 @@ commodity BND
 	assert.Equal(t, c.Id, "BND")
 	assert.Equal(t, c.Name, "Vanguard Total Bond Market ETF")
 	assert.Equal(t, c.Decimals, 0)
+	assert.Equal(t, c.Thousands, false)
+	assert.Equal(t, c.Class, "bond")
+	assert.Equal(t, c.Rounding, HalfEven)
+	assert.Equal(t, c.Quote, "json https://example.com/{symbol} data.0.price USD")
+}
+
+func Test_ConvertAt(t *testing.T) {
+	xyz := &Commodity{Id: "XYZ", Decimals: 2}
+	for _, p := range []*Price{
+		{Commodity: xyz, Currency: cad, Value: MustParseAmount("3", cad), Time: MustParseDate("2000/03/01")},
+		{Commodity: xyz, Currency: cad, Value: MustParseAmount("2", cad), Time: MustParseDate("2000/02/01")},
+	} {
+		xyz.AddPrice(p)
+	}
+	for i, fix := range []struct {
+		on, out string
+	}{
+		{"2000/02/15", "20.00"},
+		{"2000/03/01", "20.00"},
+		{"2000/03/02", "30.00"},
+	} {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			amt, err := cad.ConvertAt(MustParseAmount("10", xyz), xyz, MustParseDate(fix.on))
+			assert.NoError(t, err)
+			assert.Equal(t, amt.String(), fix.out)
+			assert.Equal(t, amt.Commodity, cad)
+		})
+	}
+	_, err := cad.ConvertAt(MustParseAmount("10", xyz), xyz, MustParseDate("2000/02/01"))
+	assert.True(t, err != nil)
+}
+
+func Test_ConvertExact(t *testing.T) {
+	eur := &Commodity{Id: "EUR", Decimals: 2}
+	jpy := &Commodity{Id: "JPY", Decimals: 0}
+	on := MustParseDate("2000/01/01")
+	// JPY => EUR => CAD, the JPY price is 0.00 EUR with the EUR decimals
+	jpy.AddPrice(NewPrice(jpy, eur, on, big.NewRat(6313, 1000000)))
+	eur.AddPrice(NewPrice(eur, cad, on, big.NewRat(14600, 10000)))
+	amt, err := cad.Convert(MustParseAmount("1000000", jpy), jpy)
+	assert.NoError(t, err)
+	assert.Equal(t, amt.String(), "9216.98")
+	cad.Rounding = HalfUp
+	defer func() { cad.Rounding = "" }()
+	amt, err = cad.Convert(MustParseAmount("1", jpy), jpy)
+	assert.NoError(t, err)
+	assert.Equal(t, amt.String(), "0.01")
+}
+
+func Test_ConvertImplicit(t *testing.T) {
+	usd := &Commodity{Id: "USD", Decimals: 2}
+	bnd := &Commodity{Id: "BND", Decimals: 0}
+	// a stale implicit price in CAD doesn't beat a newer explicit path through USD
+	bnd.addImplicitPrice(NewPrice(bnd, cad, MustParseDate("2010/01/10"), big.NewRat(25, 1)))
+	bnd.AddPrice(NewPrice(bnd, usd, MustParseDate("2011/01/10"), big.NewRat(21, 1)))
+	usd.AddPrice(NewPrice(usd, cad, MustParseDate("2011/01/10"), big.NewRat(125, 100)))
+	amt, err := cad.Convert(MustParseAmount("10", bnd), bnd)
+	assert.NoError(t, err)
+	assert.Equal(t, amt.String(), "262.50")
+	// implicit prices are used when there is no explicit path
+	eur := &Commodity{Id: "EUR", Decimals: 2}
+	eur.addImplicitPrice(NewPrice(eur, cad, MustParseDate("2010/01/10"), big.NewRat(15, 10)))
+	amt, err = cad.Convert(MustParseAmount("10", eur), eur)
+	assert.NoError(t, err)
+	assert.Equal(t, amt.String(), "15.00")
 }
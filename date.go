@@ -37,9 +37,11 @@ func (d *Date) Set(s string) (err error) {
 var DateFormat = "2006/01/02"
 var MonthFormat = "2006/01"
 var YearFormat = "2006"
+var TimeFormat = "15:04"
 
 var ymd = rex.MustCompile(`` +
-	`((?P<ymd>((?P<ymdy>\d\d(\d\d)?)/)?(?P<ymdm>\d{1,2})/(?P<ymdd>\d{1,2}))|` +
+	`((?P<iso>(?P<isoy>\d{4})-(?P<isom>\d{1,2})-(?P<isod>\d{1,2}))|` +
+	`(?P<ymd>((?P<ymdy>\d\d(\d\d)?)/)?(?P<ymdm>\d{1,2})/(?P<ymdd>\d{1,2}))|` +
 	`(?P<ym>(?P<ymy>\d{4})(/(?P<ymm>\d{1,2}))?))`)
 var offset = rex.MustCompile(`(?P<offset>[+-]\d+[d|w|m|y])`)
 var DateREX = rex.MustCompile(`(?P<date>%s?%s?)`, ymd, offset)
@@ -52,22 +54,46 @@ func mustParseDate(match map[string]string, idx int) time.Time {
 	return d
 }
 
+// withTimeOfDay returns the date with the time of day (HH:MM)
+func withTimeOfDay(date time.Time, hhmm string) (time.Time, error) {
+	tod, err := time.Parse(TimeFormat, hhmm)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time of day: %s", hhmm)
+	}
+	y, m, d := date.Date()
+	return time.Date(y, m, d, tod.Hour(), tod.Minute(), 0, 0, time.UTC), nil
+}
+
 func MustParseDate(s string) time.Time {
 	match := DateREX.Match([]byte(s))
 	return mustParseDate(match, 0)
 }
 
 func parseDate(match map[string]string, idx int) (t time.Time, err error) {
+	return parseDateFrom(match, idx, time.Date(Year, time.Month(Month), Day, 12, 0, 0, 0, time.UTC))
+}
+
+// parseDateFrom parses the date relative to the reference date,
+// i.e. a date without a year or an offset is resolved against it rather than today.
+func parseDateFrom(match map[string]string, idx int, ref time.Time) (t time.Time, err error) {
+	// names of the subexpressions are suffixed with the index
+	// if the expression includes multiple dates
+	suffix := ""
 	if idx > 0 {
-		return t, fmt.Errorf("multiple date fields not implemented!")
+		suffix = strconv.Itoa(idx)
 	}
-	// Set date to today
-	y, m, d := Year, Month, Day
-	offset := match["offset"]
-	if match["ymd"] != "" {
-		d, _ = strconv.Atoi(match["ymdd"])
-		mm, _ := strconv.Atoi(match["ymdm"])
-		if yy := match["ymdy"]; yy != "" {
+	get := func(name string) string { return match[name+suffix] }
+	// Set date to the reference date
+	y, m, d := ref.Year(), int(ref.Month()), ref.Day()
+	offset := get("offset")
+	if get("iso") != "" {
+		y, _ = strconv.Atoi(get("isoy"))
+		m, _ = strconv.Atoi(get("isom"))
+		d, _ = strconv.Atoi(get("isod"))
+	} else if get("ymd") != "" {
+		d, _ = strconv.Atoi(get("ymdd"))
+		mm, _ := strconv.Atoi(get("ymdm"))
+		if yy := get("ymdy"); yy != "" {
 			yyy, _ := strconv.Atoi(yy)
 			if yyy < 100 {
 				yyy = y/1000*1000 + yyy
@@ -88,10 +114,10 @@ func parseDate(match map[string]string, idx int) (t time.Time, err error) {
 			}
 		}
 		m = mm
-	} else if match["ym"] != "" {
+	} else if get("ym") != "" {
 		d, m = 1, 1
-		y, _ = strconv.Atoi(match["ymy"])
-		if mm := match["ymm"]; mm != "" {
+		y, _ = strconv.Atoi(get("ymy"))
+		if mm := get("ymm"); mm != "" {
 			m, _ = strconv.Atoi(mm)
 		}
 	} else if offset == "" {
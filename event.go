@@ -0,0 +1,194 @@
+package coin
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mkobetic/coin/check"
+	"github.com/mkobetic/coin/rex"
+)
+
+// Event is a commodity split or rename (merge) taking effect on a date.
+// Quantities and prices of the From commodity before the date are converted
+// to the To commodity (the same commodity for splits) using the Ratio
+// (new units per old unit), so that the history lines up with the new units.
+type Event struct {
+	Date   time.Time
+	FromId string
+	ToId   string
+	Ratio  *big.Rat
+
+	From, To *Commodity
+	kind     string // split, rename or merge
+
+	line uint
+	file string
+}
+
+var Events []*Event
+
+var ratioREX = rex.MustCompile(`(?P<ratio>\d+(\.\d+)?([:/]\d+(\.\d+)?)?)`)
+var splitREX = rex.MustCompile(`(?P<kind>split)\s+%s\s+%s\s+%s`, DateREX, CommodityREX, ratioREX)
+var renameREX = rex.MustCompile(`(?P<kind>rename|merge)\s+%s\s+%s\s+%s(\s+%s)?`, DateREX, CommodityREX, CommodityREX, ratioREX)
+
+/*
+split 2010/06/01 XYZ 3:1
+rename 2010/06/01 ABC XYZ
+merge 2010/06/01 ABC XYZ 0.8
+*/
+func (p *Parser) parseEvent(fn string) (*Event, error) {
+	e := &Event{line: p.lineNr, file: fn}
+	if match := splitREX.Match(p.Bytes()); match != nil {
+		e.kind, e.FromId, e.ToId = match["kind"], match["commodity"], match["commodity"]
+		e.Date = mustParseDate(match, 0)
+		e.Ratio = parseRatio(match["ratio"])
+	} else if match := renameREX.Match(p.Bytes()); match != nil {
+		e.kind, e.FromId, e.ToId = match["kind"], match["commodity1"], match["commodity2"]
+		e.Date = mustParseDate(match, 0)
+		e.Ratio = big.NewRat(1, 1)
+		if r := match["ratio"]; r != "" {
+			e.Ratio = parseRatio(r)
+		}
+	} else {
+		return nil, fmt.Errorf("invalid %s line: %s", strings.Fields(p.Text())[0], p.Text())
+	}
+	if e.Ratio == nil || e.Ratio.Sign() == 0 {
+		return nil, fmt.Errorf("%s - invalid ratio: %s", e.Location(), p.Text())
+	}
+	p.Scan() // advance to next line before returning
+	return e, nil
+}
+
+// parseRatio parses ratios like 3:1, 3/1, 3 or 0.8, nil if invalid
+func parseRatio(s string) *big.Rat {
+	r, ok := new(big.Rat).SetString(strings.Replace(s, ":", "/", 1))
+	if !ok {
+		return nil
+	}
+	return r
+}
+
+func (e *Event) Write(w io.Writer, ledger bool) error {
+	ratio := e.Ratio.RatString()
+	if !e.Ratio.IsInt() {
+		ratio = strings.Replace(ratio, "/", ":", 1)
+	}
+	date := e.Date.Format(DateFormat)
+	var err error
+	if e.kind == "split" {
+		_, err = fmt.Fprintf(w, "split %s %s %s\n", date, e.FromId, ratio)
+	} else {
+		_, err = fmt.Fprintf(w, "%s %s %s %s %s\n", e.kind, date, e.FromId, e.ToId, ratio)
+	}
+	return err
+}
+
+func (e *Event) String() string {
+	var b strings.Builder
+	e.Write(&b, false)
+	return b.String()
+}
+
+func (e *Event) Location() string {
+	return fmt.Sprintf("%s:%d", e.file, e.line)
+}
+
+// convert returns the amount in From commodity converted to To commodity
+func (e *Event) convert(a *Amount) *Amount {
+	return e.To.NewAmountRat(new(big.Rat).Mul(a.Rat(), e.Ratio))
+}
+
+// convertPrice converts the price of From commodity per unit
+// to the price per unit of To commodity.
+func (e *Event) convertPrice(p *Price) {
+	cur := p.Value.Commodity
+	rate := new(big.Rat).Quo(p.ExactRate(), e.Ratio)
+	p.setRate(cur, rate, decimalsOf(rate, p.decimals))
+}
+
+// convertCurrency converts the price in From commodity to the price in To commodity.
+func (e *Event) convertCurrency(p *Price) {
+	rate := new(big.Rat).Mul(p.ExactRate(), e.Ratio)
+	p.setRate(e.To, rate, decimalsOf(rate, max(p.decimals, e.To.Decimals)))
+}
+
+// ResolveEvents applies the loaded commodity events in date order to the loaded prices,
+// account commodities and transaction postings. It has to be called before they are resolved.
+func ResolveEvents() {
+	sort.SliceStable(Events, func(i, j int) bool {
+		return Events[i].Date.Before(Events[j].Date)
+	})
+	for _, e := range Events {
+		e.From = MustFindCommodity(e.FromId, e.Location())
+		e.To = MustFindCommodity(e.ToId, e.Location())
+		e.applyToPrices()
+		if e.From == e.To {
+			continue
+		}
+		for _, a := range AccountsByName {
+			if a.CommodityId == e.FromId {
+				a.CommodityId = e.ToId
+			}
+		}
+	}
+	for _, t := range Transactions {
+		for _, s := range t.Postings {
+			for _, e := range Events {
+				e.applyToPosting(s)
+			}
+		}
+	}
+	Events = nil // each event must be applied only once
+}
+
+func (e *Event) applyToPrices() {
+	// the own prices of the To commodity take precedence over the converted ones
+	first := map[string]time.Time{}
+	if e.From != e.To {
+		for _, p := range Prices {
+			if p.CommodityId == e.ToId {
+				if f, ok := first[p.currencyId]; !ok || p.Time.Before(f) {
+					first[p.currencyId] = p.Time
+				}
+			}
+		}
+	}
+	var prices []*Price
+	for _, p := range Prices {
+		if !p.Time.Before(e.Date) {
+			prices = append(prices, p)
+			continue
+		}
+		if p.CommodityId == e.FromId {
+			if f, ok := first[p.currencyId]; ok && !p.Time.Before(f) {
+				continue // To commodity has its own price
+			}
+			p.CommodityId = e.ToId
+			e.convertPrice(p)
+		} else if p.Value.Commodity == e.From {
+			p.currencyId = e.ToId
+			e.convertCurrency(p)
+		}
+		prices = append(prices, p)
+	}
+	Prices = prices
+}
+
+func (e *Event) applyToPosting(s *Posting) {
+	if s.Transaction.Posted.Before(e.Date) {
+		if s.Quantity != nil && s.Quantity.Commodity == e.From {
+			s.Quantity = e.convert(s.Quantity)
+		}
+		if s.Balance != nil && s.Balance.Commodity == e.From {
+			s.Balance = e.convert(s.Balance)
+		}
+		return
+	}
+	check.If(e.From == e.To || s.Quantity == nil || s.Quantity.Commodity != e.From,
+		"%s was replaced by %s on %s (%s): %s\n",
+		e.FromId, e.ToId, e.Date.Format(DateFormat), e.Location(), s.Transaction.Location())
+}
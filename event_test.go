@@ -0,0 +1,52 @@
+package coin
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/mkobetic/coin/assert"
+)
+
+func Test_ParseEvent(t *testing.T) {
+	r := strings.NewReader(`
+split 2010/06/01 XYZ 3:1
+rename 2010/08/01 ABC DEF
+merge 2010/09/01 DEF GHI 0.8
+`)
+	p := NewParser(r)
+	for _, fix := range []struct {
+		from, to, date, ratio, line string
+	}{
+		{"XYZ", "XYZ", "2010/06/01", "3", "split 2010/06/01 XYZ 3\n"},
+		{"ABC", "DEF", "2010/08/01", "1", "rename 2010/08/01 ABC DEF 1\n"},
+		{"DEF", "GHI", "2010/09/01", "4/5", "merge 2010/09/01 DEF GHI 4:5\n"},
+	} {
+		i, err := p.Next("")
+		assert.NoError(t, err)
+		e, ok := i.(*Event)
+		assert.Equal(t, ok, true)
+		assert.Equal(t, e.FromId, fix.from)
+		assert.Equal(t, e.ToId, fix.to)
+		assert.Equal(t, e.Date.Format(DateFormat), fix.date)
+		assert.Equal(t, e.Ratio.RatString(), fix.ratio)
+		assert.Equal(t, e.String(), fix.line)
+	}
+}
+
+func Test_EventConvert(t *testing.T) {
+	xyz := &Commodity{Id: "XYZ", Decimals: 0}
+	def := &Commodity{Id: "DEF", Decimals: 3}
+	e := &Event{From: xyz, To: def, Ratio: parseRatio("1.5")}
+	assert.Equal(t, e.convert(MustParseAmount("10", xyz)).String(), "15.000")
+	p := NewPrice(xyz, cad, MustParseDate("2010/01/01"), big.NewRat(9, 1))
+	e.convertPrice(p)
+	assert.Equal(t, p.Value.String(), "6.00")
+	assert.Equal(t, p.String(), "P 2010/01/01 XYZ 6.00 CAD\n")
+	e.Ratio = parseRatio("3")
+	e.convertPrice(p)
+	assert.Equal(t, p.Value.String(), "2.00")
+	e.convertPrice(p)
+	assert.Equal(t, p.Value.String(), "0.66")
+	assert.Equal(t, p.String(), "P 2010/01/01 XYZ 0.6666666667 CAD\n")
+}
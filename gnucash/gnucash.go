@@ -72,6 +72,10 @@ func mustParseTimeStamp(ts string) time.Time {
 
 // GncNumeric is a fraction
 func mustParseAmount(f string, c *coin.Commodity) *coin.Amount {
+	return c.NewAmountRat(mustParseFraction(f))
+}
+
+func mustParseFraction(f string) *big.Rat {
 	values := strings.Split(f, "/")
 	if len(values) != 2 {
 		panic("invalid fraction: " + f)
@@ -83,7 +87,7 @@ func mustParseAmount(f string, c *coin.Commodity) *coin.Amount {
 	if den.Sign() <= 0 {
 		panic("invalid denominator: " + den.String())
 	}
-	return coin.NewAmountFrac(num, den, c)
+	return new(big.Rat).SetFrac(num, den)
 }
 
 /*
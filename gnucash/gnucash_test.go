@@ -58,9 +58,9 @@ func Test_Unmarshaling(t *testing.T) {
 		assert.Equal(t, coin.Commodities[id].String(), exp)
 	}
 	for i, exp := range []string{
-		"P 2013/11/05 CAD 0.94 USD\n",
-		"P 2013/11/13 CAD 0.94 USD\n",
-		"P 2015/12/08 ZLB 26.51 CAD\n",
+		"P 2013/11/05 CAD 0.9425070688 USD\n",
+		"P 2013/11/13 CAD 0.9478672986 USD\n",
+		"P 2015/12/08 ZLB 26.5199 CAD\n",
 	} {
 		assert.Equal(t, coin.Prices[i].String(), exp)
 	}
@@ -38,11 +38,11 @@ type Price struct {
 
 func resolvePrices(prices []*Price) {
 	for _, gp := range prices {
-		p := &coin.Price{}
-		p.Commodity = coin.Commodities[gp.CommodityId]
-		p.Currency = coin.Commodities[gp.CurrencyId]
-		p.Value = mustParseAmount(gp.ValueFraction, p.Currency)
-		p.Time = mustParseTimeStamp(gp.Date)
+		p := coin.NewPrice(
+			coin.Commodities[gp.CommodityId],
+			coin.Commodities[gp.CurrencyId],
+			mustParseTimeStamp(gp.Date),
+			mustParseFraction(gp.ValueFraction))
 		p.Commodity.Prices[p.Currency] =
 			append(p.Commodity.Prices[p.Currency], p)
 		coin.Prices = append(coin.Prices, p)
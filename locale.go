@@ -0,0 +1,138 @@
+package coin
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Negative number styles
+const (
+	Minus  = "minus"  // -1234.56
+	Parens = "parens" // (1234.56)
+)
+
+// Locale controls how dates and numbers are presented in reports and exports.
+// The syntax of the ledger files is not affected, it always uses DateFormat,
+// the . decimal mark and the thousands separators of the commodity formats.
+type Locale struct {
+	DateFormat string // time layout of dates, e.g. 02.01.2006
+	Decimal    string // decimal mark
+	Thousands  string // digit grouping separator, if empty only commodities with thousands format are grouped
+	Negative   string // style of negative numbers, minus or parens
+}
+
+var (
+	// ledgerLocale is the presentation used in the ledger files
+	ledgerLocale = &Locale{DateFormat: DateFormat, Decimal: ".", Negative: Minus}
+
+	// Output is the presentation used in reports and exports, the ledger presentation by default.
+	// Commands configure it with COINLOCALE (see SetOutputLocale),
+	// e.g. COINLOCALE="date=02.01.2006 decimal=, thousands=. negative=parens"
+	Output = &Locale{DateFormat: DateFormat, Decimal: ".", Negative: Minus}
+)
+
+// SetOutputLocale sets the Output locale from the COINLOCALE environment variable.
+func SetOutputLocale() error {
+	l, err := ParseLocale(os.Getenv("COINLOCALE"))
+	if err != nil {
+		return err
+	}
+	Output = l
+	return nil
+}
+
+// MustParseLocale parses space separated key=value settings
+// (date, decimal, thousands and negative) overriding the defaults.
+func MustParseLocale(s string) *Locale {
+	l, err := ParseLocale(s)
+	if err != nil {
+		panic(err)
+	}
+	return l
+}
+
+func ParseLocale(s string) (*Locale, error) {
+	l := *ledgerLocale
+	for _, setting := range strings.Fields(s) {
+		key, value, ok := strings.Cut(setting, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid locale setting %s, expected key=value", setting)
+		}
+		switch key {
+		case "date":
+			l.DateFormat = value
+		case "decimal":
+			l.Decimal = value
+		case "thousands":
+			l.Thousands = value
+		case "negative":
+			l.Negative = value
+		default:
+			return nil, fmt.Errorf("unknown locale setting %s", key)
+		}
+	}
+	if l.DateFormat == "" || l.Decimal == "" {
+		return nil, fmt.Errorf("locale date and decimal must not be empty")
+	}
+	if l.Decimal == l.Thousands {
+		return nil, fmt.Errorf("locale decimal and thousands must differ")
+	}
+	if l.Negative != Minus && l.Negative != Parens {
+		return nil, fmt.Errorf("invalid locale negative %s, use %s or %s", l.Negative, Minus, Parens)
+	}
+	return &l, nil
+}
+
+// Number presents a decimal number written in the ledger syntax (e.g. -1234.56),
+// a trailing % is preserved. Whole numbers are not grouped,
+// they are usually counts or years rather than amounts.
+func (l *Locale) Number(s string) string {
+	percent := strings.HasSuffix(s, "%")
+	s = strings.TrimSuffix(s, "%")
+	thousands := l.Thousands
+	if !strings.Contains(s, ".") {
+		thousands = ""
+	}
+	s = l.number(s, thousands)
+	if percent {
+		s += "%"
+	}
+	return s
+}
+
+// separator returns the thousands separator to use for amounts of commodity c
+func (l *Locale) separator(c *Commodity) string {
+	if l.Thousands != "" || !c.Thousands {
+		return l.Thousands
+	}
+	if l.Decimal == "," {
+		return "."
+	}
+	return ","
+}
+
+// number presents a decimal number written in the ledger syntax
+// grouping the digits of the whole part with the thousands separator (if not empty)
+func (l *Locale) number(s string, thousands string) string {
+	negative := strings.HasPrefix(s, "-")
+	whole, frac, hasFrac := strings.Cut(strings.TrimPrefix(s, "-"), ".")
+	var b strings.Builder
+	for i, d := range whole {
+		if i > 0 && thousands != "" && (len(whole)-i)%3 == 0 {
+			b.WriteString(thousands)
+		}
+		b.WriteRune(d)
+	}
+	if hasFrac {
+		b.WriteString(l.Decimal + frac)
+	}
+	switch {
+	case !negative:
+		return b.String()
+	case l.Negative == Parens:
+		return "(" + b.String() + ")"
+	default:
+		return "-" + b.String()
+	}
+}
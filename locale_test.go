@@ -0,0 +1,66 @@
+package coin
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mkobetic/coin/assert"
+)
+
+func Test_ParseLocale(t *testing.T) {
+	l, err := ParseLocale("")
+	assert.NoError(t, err)
+	assert.Equal(t, *l, *ledgerLocale)
+
+	l, err = ParseLocale("date=02.01.2006 decimal=, thousands=. negative=parens")
+	assert.NoError(t, err)
+	assert.Equal(t, l.DateFormat, "02.01.2006")
+	assert.Equal(t, l.Decimal, ",")
+	assert.Equal(t, l.Thousands, ".")
+	assert.Equal(t, l.Negative, Parens)
+
+	for _, s := range []string{"date", "decimal=", "decimal=. thousands=.", "negative=red", "currency=EUR"} {
+		_, err = ParseLocale(s)
+		assert.True(t, err != nil, "%s should fail", s)
+	}
+}
+
+func Test_LocaleNumber(t *testing.T) {
+	l := MustParseLocale("decimal=, thousands=. negative=parens")
+	for i, fix := range []struct{ in, out string }{
+		{"0.50", "0,50"},
+		{"-1234567.89", "(1.234.567,89)"},
+		{"12.5%", "12,5%"},
+		{"2024", "2024"},
+		{"-3", "(3)"},
+	} {
+		assert.Equal(t, l.Number(fix.in), fix.out, "%d. not equal", i)
+	}
+}
+
+func Test_LocaleAmount(t *testing.T) {
+	defer func(l *Locale) { Output = l }(Output)
+	Output = MustParseLocale("decimal=, negative=parens")
+	eur := &Commodity{Id: "EUR", Decimals: 2, Thousands: true}
+	amt := MustParseAmount("-12345.6", eur)
+	assert.Equal(t, fmt.Sprintf("%a", amt), "(12.345,60)")
+	assert.Equal(t, amt.Width(2), 11)
+	assert.Equal(t, fmt.Sprintf("%12a", MustParseAmount("1.5", cad)), "        1,50")
+	// ledger syntax is not affected
+	assert.Equal(t, fmt.Sprintf("%f", amt), "-12,345.60")
+	var b strings.Builder
+	assert.NoError(t, amt.Write(&b, false))
+	assert.Equal(t, b.String(), "-12,345.60 EUR")
+}
+
+func Test_SetOutputLocale(t *testing.T) {
+	defer func(l *Locale) { Output = l }(Output)
+	t.Setenv("COINLOCALE", "date=02.01.2006 decimal=,")
+	assert.NoError(t, SetOutputLocale())
+	assert.Equal(t, Output.DateFormat, "02.01.2006")
+	assert.Equal(t, Output.Decimal, ",")
+	t.Setenv("COINLOCALE", "decimal")
+	assert.True(t, SetOutputLocale() != nil, "invalid setting should fail")
+	assert.Equal(t, Output.Decimal, ",")
+}
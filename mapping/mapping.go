@@ -0,0 +1,191 @@
+// Package mapping reads the source mappings used by the importers (csv2coin, prices2coin)
+// to extract named values from the fields of the imported records.
+package mapping
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mkobetic/coin/check"
+	"github.com/mkobetic/coin/rex"
+)
+
+type Field struct {
+	idx       *int     // field index for direct fields
+	out       string   // field output template
+	condField string   // names the field to match for conditional derived fields
+	re        *rex.Exp // extraction rex for direct fields or conditional rex for derived fields
+}
+
+// Column returns a direct field using the entire contents of the record field idx.
+func Column(idx int) *Field {
+	return &Field{idx: &idx}
+}
+
+func (f *Field) Value(row []string, fields map[string]Fields) string {
+	if f.idx == nil {
+		return f.derivedField(row, fields)
+	}
+	s := row[*f.idx]
+	if f.re == nil {
+		return s
+	}
+	return f.directField(s)
+}
+
+func (f *Field) derivedField(row []string, fields map[string]Fields) string {
+	if f.condField != "" && len(f.re.Match([]byte(fields[f.condField].Value(row, fields)))) == 0 {
+		return ""
+	}
+	parts := strings.Split(f.out, "$")
+	if len(parts) == 1 {
+		return f.out
+	}
+	out := []string{parts[0]}
+	for _, p := range parts[1:] {
+		if p[0] == '{' {
+			i := strings.IndexByte(p, '}')
+			if i > 0 {
+				f := p[1:i]
+				out = append(out, fields[f].Value(row, fields)+p[i+1:])
+				continue
+			}
+		}
+		out = append(out, p)
+	}
+	return strings.Join(out, "")
+}
+
+func (f *Field) directField(s string) string {
+	match := f.re.Match([]byte(s))
+	if match == nil {
+		return ""
+	}
+	parts := strings.Split(f.out, "$")
+	if len(parts) == 1 {
+		return f.out
+	}
+	out := []string{parts[0]}
+	for _, p := range parts[1:] {
+		if '0' <= p[0] && p[0] <= '9' {
+			out = append(out, match[p[0:1]]+p[1:])
+		} else {
+			out = append(out, p)
+		}
+	}
+	return strings.Join(out, "")
+}
+
+type Fields []*Field
+
+func (fs Fields) Value(row []string, fields map[string]Fields) string {
+	for _, f := range fs {
+		if v := f.Value(row, fields); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+type Source struct {
+	Name   string
+	Skip   int // number of header lines to skip
+	Fields map[string]Fields
+}
+
+// Columns returns the minimal number of columns of a row
+// required by the direct fields of the source.
+func (s *Source) Columns() (n int) {
+	for _, fs := range s.Fields {
+		for _, f := range fs {
+			if f.idx != nil && *f.idx >= n {
+				n = *f.idx + 1
+			}
+		}
+	}
+	return n
+}
+
+var sourceREX = rex.MustCompile(`^(?P<source>\w+)(\s+(?P<skip>\d+))?\s*$`)
+var derivedFieldRex = rex.MustCompile(`"(?P<code>.*)"(\s+(?P<condField>\w+)\s+(?P<condRex>.+))?`)
+var directFieldRex = rex.MustCompile(`(?P<rowIdx>\d+)(\s+"(?P<out>.+)"\s+(?P<rex>.+))?`)
+var fieldREX = rex.MustCompile(`^\s+(?P<field>\w+)\s+(%s|%s)$`, directFieldRex, derivedFieldRex)
+
+// ScanSource reads the source starting at line, nil if the line doesn't start a source.
+// The scanner is left on the line following the source.
+func ScanSource(line []byte, s *bufio.Scanner) *Source {
+	match := sourceREX.Match(line)
+	if match == nil {
+		return nil
+	}
+	skip, err := strconv.Atoi(match["skip"])
+	check.NoError(err, "parsing header line count")
+	src := &Source{Name: match["source"], Skip: skip, Fields: make(map[string]Fields)}
+	check.If(s.Scan(), "reading next source line: %s\n", s.Err())
+	line = s.Bytes()
+	for {
+		match = fieldREX.Match(line)
+		if match == nil {
+			break
+		}
+		name := match["field"]
+		var field Field
+		if code := match["code"]; code != "" {
+			field.out = code
+			if field.condField = match["condField"]; field.condField != "" {
+				field.re = rex.MustCompile(strings.TrimSpace(match["condRex"]))
+			}
+		} else {
+			idx, err := strconv.Atoi(match["rowIdx"])
+			check.NoError(err, "invalid field row index: %s\n", idx)
+			field.idx = &idx
+			field.out = strings.TrimSpace(match["out"])
+			if ex := match["rex"]; ex != "" {
+				field.re = rex.MustCompile(strings.TrimSpace(ex))
+			}
+		}
+		src.Fields[name] = append(src.Fields[name], &field)
+		if !s.Scan() {
+			break
+		}
+		line = s.Bytes()
+	}
+	return src
+}
+
+// Write writes the source in the rules file format, the values named by labels
+// are written first followed by any auxiliary values.
+func (s *Source) Write(w io.Writer, labels []string) {
+	fmt.Fprintf(w, "%s %d\n", s.Name, s.Skip)
+	names := append([]string{}, labels...)
+	var aux []string
+	for n := range s.Fields {
+		if !slices.Contains(labels, n) {
+			aux = append(aux, n)
+		}
+	}
+	sort.Strings(aux)
+	for _, n := range append(names, aux...) {
+		for _, f := range s.Fields[n] {
+			switch {
+			case f.idx == nil && f.re == nil:
+				fmt.Fprintf(w, "  %s \"%s\"\n", n, f.out)
+			case f.idx == nil:
+				fmt.Fprintf(w, "  %s \"%s\" %s %s\n", n, f.out, f.condField, f.re)
+			case f.re == nil:
+				fmt.Fprintf(w, "  %s %d\n", n, *f.idx)
+			default:
+				fmt.Fprintf(w, "  %s %d \"%s\" %s\n", n, *f.idx, f.out, f.re)
+			}
+		}
+	}
+}
+
+func (s *Source) Value(field string, row []string) string {
+	return s.Fields[field].Value(row, s.Fields)
+}
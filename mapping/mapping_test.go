@@ -1,4 +1,4 @@
-package main
+package mapping
 
 import (
 	"bufio"
@@ -31,9 +31,9 @@ mybrokerage2 2
 	line := s.Bytes()
 	src := ScanSource(line, s)
 	assert.NotNil(t, src)
-	assert.Equal(t, src.name, "mybank")
-	assert.Equal(t, src.skip, 1)
-	assert.Equal(t, len(src.fields), 5)
+	assert.Equal(t, src.Name, "mybank")
+	assert.Equal(t, src.Skip, 1)
+	assert.Equal(t, len(src.Fields), 5)
 	get := func(name string, row ...string) string { return src.Value(name, row) }
 	assert.Equal(t, get("amount", "AcctID", "12/11", "desc", "50.45", "note"), "50.45")
 	assert.Equal(t, get("amount", "AcctID", "12/11", "desc", "", "note VALUE = 70.777"), "-70.777")
@@ -43,9 +43,9 @@ mybrokerage2 2
 
 	src = ScanSource(s.Bytes(), s)
 	assert.NotNil(t, src)
-	assert.Equal(t, src.name, "mybrokerage2")
-	assert.Equal(t, src.skip, 2)
-	assert.Equal(t, len(src.fields), 9)
+	assert.Equal(t, src.Name, "mybrokerage2")
+	assert.Equal(t, src.Skip, 2)
+	assert.Equal(t, len(src.Fields), 9)
 	assert.Equal(t, get("note", "AcctID", "12/11", "desc", "50.45", "VBAL", "100.00", "CAD"), "50.45 VBAL")
 	assert.Equal(t, get("symbol", "AcctID", "12/11", "desc", "50.45", "VBAL", "100.00", "CAD"), "")
 	assert.Equal(t, get("symbol", "AcctID", "12/11", "DRIP", "50.45", "VBAL", "100.00", "CAD"), "VBAL")
@@ -51,6 +51,10 @@ func (p *Parser) Next(fn string) (Item, error) {
 		return p.parseTest(fn)
 	case bytes.HasPrefix(line, []byte("P ")):
 		return p.parsePrice(fn)
+	case bytes.HasPrefix(line, []byte("split ")),
+		bytes.HasPrefix(line, []byte("rename ")),
+		bytes.HasPrefix(line, []byte("merge ")):
+		return p.parseEvent(fn)
 	case '0' <= line[0] && line[0] <= '9':
 		return p.parseTransaction(fn)
 	default:
@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"time"
+
+	"github.com/mkobetic/coin/rex"
 )
 
 type Posting struct {
@@ -13,13 +16,58 @@ type Posting struct {
 
 	Transaction     *Transaction
 	Account         *Account
-	Quantity        *Amount // posting amount
-	Balance         *Amount // account balance as of this posting
-	BalanceAsserted bool    // was balance explicitly asserted in the ledger
+	Quantity        *Amount   // posting amount
+	Balance         *Amount   // account balance as of this posting
+	BalanceAsserted bool      // was balance explicitly asserted in the ledger
+	Effective       time.Time // effective date override from the notes ([=DATE]), zero otherwise
 
 	accountName string
 }
 
+var effectiveDateREX = rex.MustCompile(`\[=%s\]`, DateREX)
+
+// parseEffectiveDate returns the date of the first [=DATE] in the notes, zero if none.
+// A date without a year is taken relative to the posted date.
+func parseEffectiveDate(posted time.Time, notes ...string) time.Time {
+	for _, n := range notes {
+		if match := effectiveDateREX.Match([]byte(n)); match != nil {
+			if d, err := parseDateFrom(match, 0, posted); err == nil {
+				return d
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// EffectiveDate returns the date the posting takes effect, which is the posting date override,
+// the effective date of the transaction or the posted date of the transaction.
+func (s *Posting) EffectiveDate() time.Time {
+	if !s.Effective.IsZero() {
+		return s.Effective
+	}
+	if !s.Transaction.Effective.IsZero() {
+		return s.Transaction.Effective
+	}
+	return s.Transaction.Posted
+}
+
+// Date returns the effective date of the posting if effective is set, otherwise the posted date.
+func (s *Posting) Date(effective bool) time.Time {
+	if effective {
+		return s.EffectiveDate()
+	}
+	return s.Transaction.Posted
+}
+
+// Time returns the time used to order postings by their (effective) date,
+// the time of day of the transaction applies only to its posted date.
+func (s *Posting) Time(effective bool) time.Time {
+	if d := s.Date(effective); !d.Equal(s.Transaction.Posted) {
+		return d
+	}
+	return s.Transaction.PostedAt()
+}
+
 func (s *Posting) Write(w io.Writer, accountOffset, accountWidth, amountWidth int, ledger bool) error {
 	notes := s.Notes
 	commodity := s.Quantity.Commodity
@@ -85,5 +133,8 @@ func (p *Posting) MarshalJSON() ([]byte, error) {
 	if p.Tags != nil {
 		value["tags"] = p.Tags
 	}
+	if !p.Effective.IsZero() {
+		value["effective"] = p.Effective.Format(DateFormat)
+	}
 	return json.MarshalIndent(value, "", "\t")
 }
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math/big"
+	"sort"
 	"strings"
 	"time"
 
@@ -13,24 +15,97 @@ import (
 type Price struct {
 	Commodity *Commodity
 	Currency  *Commodity
-	Value     *Amount
+	Value     *Amount  // Rate rounded to the currency decimals
+	Rate      *big.Rat // exact price, as written or derived (nil means Value)
 	Time      time.Time
+	Implicit  bool // derived from a transaction rather than an explicit price entry
 
 	CommodityId string
 	currencyId  string
+	decimals    int // decimals to write the Rate with
 	line        uint
 	file        string
 }
 
+// maxPriceDecimals is how many decimals beyond the currency decimals
+// are written for derived prices that cannot be written exactly.
+const maxPriceDecimals = 8
+
+// NewPrice returns the price of commodity c in currency cur at time t.
+func NewPrice(c, cur *Commodity, t time.Time, rate *big.Rat) *Price {
+	p := &Price{
+		Commodity:   c,
+		Currency:    cur,
+		Time:        t,
+		CommodityId: c.Id,
+		currencyId:  cur.Id,
+	}
+	p.setRate(cur, rate, decimalsOf(rate, cur.Decimals))
+	return p
+}
+
+// setRate sets the exact price in currency cur
+// and the Value rounded using the currency rounding mode.
+func (p *Price) setRate(cur *Commodity, rate *big.Rat, decimals int) {
+	p.Rate = rate
+	p.Value = cur.NewAmountRat(rate)
+	p.decimals = decimals
+}
+
+// RateString returns the exact price as written, without the currency
+func (p *Price) RateString() string {
+	if p.Rate == nil {
+		return p.Value.String()
+	}
+	return p.Rate.FloatString(p.decimals)
+}
+
+// ExactRate returns the exact price, which is Value if Rate is not set
+func (p *Price) ExactRate() *big.Rat {
+	if p.Rate != nil {
+		return p.Rate
+	}
+	return p.Value.Rat()
+}
+
+// decimalsOf returns the number of decimals required to write r exactly,
+// at least least and at most least + maxPriceDecimals.
+func decimalsOf(r *big.Rat, least int) int {
+	d := new(big.Int).Set(r.Denom())
+	twos, fives := 0, 0
+	for d.Bit(0) == 0 {
+		d.Rsh(d, 1)
+		twos++
+	}
+	m := new(big.Int)
+	for {
+		q, _ := new(big.Int).QuoRem(d, big.NewInt(5), m)
+		if m.Sign() != 0 {
+			break
+		}
+		d = q
+		fives++
+	}
+	decimals := max(twos, fives)
+	if d.Cmp(big1) != 0 || decimals > least+maxPriceDecimals {
+		return least + maxPriceDecimals
+	}
+	return max(decimals, least)
+}
+
 var Prices []*Price
 
+// ImplicitPrices are derived from transactions exchanging two commodities (see ResolveImplicitPrices)
+var ImplicitPrices []*Price
+
 func (p *Price) Write(w io.Writer, ledger bool) error {
 	date := p.Time.Format(DateFormat)
 	_, err := io.WriteString(w, "P "+date+" "+p.Commodity.SafeId(ledger)+" ")
 	if err != nil {
 		return err
 	}
-	err = p.Value.Write(w, ledger)
+	rate := ledgerLocale.number(p.RateString(), ledgerLocale.separator(p.Value.Commodity))
+	_, err = io.WriteString(w, rate+" "+p.Value.SafeId(ledger))
 	if err != nil {
 		return err
 	}
@@ -50,20 +125,26 @@ func (p *Parser) parsePrice(fn string) (*Price, error) {
 	line := p.lineNr
 	location := fmt.Sprintf("%s:%d", fn, line)
 	c := MustFindCommodity(currencyId, location)
-	amt, err := parseAmount(match["amount"], c)
-	if err != nil {
-		return nil, err
+	// keep the price as written, not just with the currency decimals
+	rate, ok := new(big.Rat).SetString(strings.ReplaceAll(match["amount"], ",", ""))
+	if !ok {
+		return nil, fmt.Errorf("%s - invalid price value: %s", location, match["amount"])
 	}
 	commodityId := string(match["commodity1"])
 	p.Scan() // advance to next line before returning
-	return &Price{
+	price := &Price{
 		Time:        date,
-		Value:       amt,
 		CommodityId: commodityId,
 		currencyId:  currencyId,
 		line:        line,
 		file:        fn,
-	}, nil
+	}
+	decimals := 0
+	if d := match["decimals"]; len(d) > 0 {
+		decimals = len(d) - 1
+	}
+	price.setRate(c, rate, max(decimals, c.Decimals))
+	return price, nil
 }
 
 func (p *Price) String() string {
@@ -85,3 +166,95 @@ func (p *Price) MarshalJSON() ([]byte, error) {
 	}
 	return json.MarshalIndent(value, "", "\t")
 }
+
+// ResolveImplicitPrices derives prices from transactions exchanging a commodity for a currency,
+// e.g. 10 VGRO for -285 CAD is a price of 28.50 CAD for VGRO on the transaction date.
+// The currency is the default commodity, a commodity of class currency or a commodity
+// used as currency of explicit prices. Implicit prices are kept in ImplicitPrices
+// and in the commodity implicit prices, unless there is an explicit price for the same date.
+// Conversions use them only when there is no conversion path through explicit prices.
+// Must be called after ResolveTransactions.
+func ResolveImplicitPrices() {
+	currencies := map[*Commodity]bool{DefaultCommodity(): true}
+	for _, c := range Commodities {
+		if c.Class == "currency" {
+			currencies[c] = true
+		}
+	}
+	explicit := map[string]bool{}
+	key := func(c, cur *Commodity, t time.Time) string {
+		return c.Id + " " + cur.Id + " " + t.Format(DateFormat)
+	}
+	for _, p := range Prices {
+		currencies[p.Currency] = true
+		explicit[key(p.Commodity, p.Currency, p.Time)] = true
+	}
+	changed := map[*Commodity]bool{}
+	for _, t := range Transactions {
+		p := t.implicitPrice(currencies)
+		if p == nil || explicit[key(p.Commodity, p.Currency, p.Time)] {
+			continue
+		}
+		ImplicitPrices = append(ImplicitPrices, p)
+		p.Commodity.addImplicitPrice(p)
+		changed[p.Commodity] = true
+	}
+	for c := range changed {
+		for _, ps := range c.ImplicitPrices {
+			sort.SliceStable(ps, func(i, j int) bool {
+				return ps[i].Time.After(ps[j].Time)
+			})
+		}
+	}
+}
+
+// implicitPrice returns the price implied by the transaction exchanging a commodity
+// for a currency, nil if the transaction doesn't exchange exactly two commodities
+// or if it isn't clear which one is the currency.
+func (t *Transaction) implicitPrice(currencies map[*Commodity]bool) *Price {
+	totals := map[*Commodity]*Amount{}
+	for _, s := range t.Postings {
+		if s.Quantity == nil {
+			return nil
+		}
+		c := s.Quantity.Commodity
+		if totals[c] == nil {
+			totals[c] = NewZeroAmount(c)
+		}
+		totals[c].Add(totals[c].Int, s.Quantity.Int)
+	}
+	if len(totals) != 2 {
+		return nil
+	}
+	var a, b *Amount
+	for _, total := range totals {
+		if a == nil {
+			a = total
+		} else {
+			b = total
+		}
+	}
+	if a.Sign() == 0 || b.Sign() == 0 || a.Sign() == b.Sign() {
+		return nil
+	}
+	// a is the commodity, b is the currency
+	switch dc := DefaultCommodity(); {
+	case b.Commodity == dc:
+	case a.Commodity == dc:
+		a, b = b, a
+	case currencies[b.Commodity] && !currencies[a.Commodity]:
+	case currencies[a.Commodity] && !currencies[b.Commodity]:
+		a, b = b, a
+	case a.Commodity.Prices[b.Commodity] != nil:
+	case b.Commodity.Prices[a.Commodity] != nil:
+		a, b = b, a
+	default:
+		return nil
+	}
+	// price = -b / a
+	rate := new(big.Rat).Quo(b.Rat(), a.Rat())
+	p := NewPrice(a.Commodity, b.Commodity, t.Posted, rate.Neg(rate))
+	p.Implicit = true
+	p.line, p.file = t.line, t.file
+	return p
+}
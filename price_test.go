@@ -17,6 +17,7 @@ func Test_ParsePrice(t *testing.T) {
 P 1988/06/29 TDB162 9.69 CAD
 P 1988/07/28 TDB162 9.58 CAD
 P 1988/08/30 TDB162 9.45 CAD
+P 1988/09/30 TDB162 0.734215 CAD
 `)
 	p := NewParser(r)
 	i, err := p.Next("")
@@ -45,4 +46,16 @@ P 1988/08/30 TDB162 9.45 CAD
 	assert.Equal(t, pr.currencyId, "CAD")
 	assert.Equal(t, pr.Time.Format(DateFormat), "1988/08/30")
 	assert.Equal(t, fmt.Sprintf("%a", pr.Value), "9.45")
+	pr.Commodity = Commodities["TDB162"]
+	assert.Equal(t, pr.String(), "P 1988/08/30 TDB162 9.45 CAD\n")
+
+	// prices keep the written precision
+	i, err = p.Next("")
+	assert.NoError(t, err)
+	pr, ok = i.(*Price)
+	assert.Equal(t, ok, true)
+	assert.Equal(t, fmt.Sprintf("%a", pr.Value), "0.73")
+	assert.Equal(t, pr.Rate.FloatString(6), "0.734215")
+	pr.Commodity = Commodities["TDB162"]
+	assert.Equal(t, pr.String(), "P 1988/09/30 TDB162 0.734215 CAD\n")
 }
@@ -22,7 +22,9 @@ type Transaction struct {
 	Postings    []*Posting
 	Tags        Tags
 
-	Posted time.Time
+	Posted    time.Time
+	Time      time.Time // posted date with the time of day if specified, zero otherwise
+	Effective time.Time // effective (auxiliary) date if specified, zero otherwise
 
 	currencyId string
 	line       uint
@@ -45,8 +47,8 @@ func (transactions TransactionsByTime) Swap(i, j int) {
 	transactions[i], transactions[j] = transactions[j], transactions[i]
 }
 func (transactions TransactionsByTime) Less(i, j int) bool {
-	return transactions[i].Posted.Before(transactions[j].Posted) ||
-		(transactions[i].Posted.Equal(transactions[j].Posted) &&
+	return transactions[i].PostedAt().Before(transactions[j].PostedAt()) ||
+		(transactions[i].PostedAt().Equal(transactions[j].PostedAt()) &&
 			!transactions[i].HasBalanceAssertions() &&
 			transactions[j].HasBalanceAssertions())
 }
@@ -78,9 +80,24 @@ func (transactions TransactionsByTime) Day(day time.Time) TransactionsByTime {
 	return transactions[start : start+count]
 }
 
+// PostedAt returns the time used to order transactions posted on the same day,
+// transactions without time of day are ordered as if posted at noon.
+func (t *Transaction) PostedAt() time.Time {
+	if t.Time.IsZero() {
+		return t.Posted
+	}
+	return t.Time
+}
+
 func (t *Transaction) Write(w io.Writer, ledger bool) error {
 	notes := t.Notes
 	line := t.Posted.Format(DateFormat) + " "
+	if !t.Effective.IsZero() {
+		line = t.Posted.Format(DateFormat) + "=" + t.Effective.Format(DateFormat) + " "
+	}
+	if !t.Time.IsZero() {
+		line += t.Time.Format(TimeFormat) + " "
+	}
 	if t.Code != "" {
 		line += "(" + t.Code + ") "
 	}
@@ -104,7 +121,7 @@ func (t *Transaction) Write(w io.Writer, ledger bool) error {
 		if l := len(s.Account.FullName); l > maxn {
 			maxn = l
 		}
-		if l := s.Quantity.Width(s.Account.Commodity.Decimals); l > maxa {
+		if l := s.Quantity.width(s.Account.Commodity.Decimals, ledgerLocale); l > maxa {
 			maxa = l
 		}
 	}
@@ -117,7 +134,7 @@ func (t *Transaction) Write(w io.Writer, ledger bool) error {
 	return nil
 }
 
-var transactionREX = rex.MustCompile(`%s(\s+\((?P<code>\w+)\))?(\s+(?P<description>\S[^;]*))?(; ?(?P<shortNote>.*))?`, DateREX)
+var transactionREX = rex.MustCompile(`%s(=%s)?(\s+(?P<time>\d{1,2}:\d{2})\b)?(\s+\((?P<code>\w+)\))?(\s+(?P<description>\S[^;]*))?(; ?(?P<shortNote>.*))?`, DateREX, DateREX)
 var postingREX = rex.MustCompile(``+
 	`\s+%s(\s+%s(\s+=\s+%s)?)?(\s*; ?(?P<shortNote>.*))?|`+
 	`\s+; ?(?P<note>.*)`,
@@ -129,12 +146,25 @@ func (p *Parser) parseTransaction(fn string) (*Transaction, error) {
 		return nil, fmt.Errorf("invalid transaction line: %s", p.Text())
 	}
 	t := &Transaction{
-		Posted:      mustParseDate(match, 0),
+		Posted:      mustParseDate(match, 1),
 		Code:        match["code"],
 		Description: strings.TrimRight(match["description"], " \t"),
 		line:        p.lineNr,
 		file:        fn,
 	}
+	if match["date2"] != "" {
+		// a date without a year is taken relative to the posted date
+		var err error
+		if t.Effective, err = parseDateFrom(match, 2, t.Posted); err != nil {
+			return nil, fmt.Errorf("%s - %s", t.Location(), err)
+		}
+	}
+	if hhmm := match["time"]; hhmm != "" {
+		var err error
+		if t.Time, err = withTimeOfDay(t.Posted, hhmm); err != nil {
+			return nil, fmt.Errorf("%s - %s", t.Location(), err)
+		}
+	}
 	if n := strings.TrimLeft(match["shortNote"], " \t"); len(n) > 0 {
 		t.Notes = []string{n}
 	}
@@ -188,8 +218,9 @@ func (p *Parser) parseTransaction(fn string) (*Transaction, error) {
 		s.Notes = append(s.Notes, notes...)
 	}
 	t.Tags = ParseTags(t.Notes...)
-	for _, p := range t.Postings {
-		p.Tags = ParseTags(p.Notes...)
+	for _, s := range t.Postings {
+		s.Tags = ParseTags(s.Notes...)
+		s.Effective = parseEffectiveDate(t.Posted, s.Notes...)
 	}
 	return t, p.Err()
 }
@@ -239,6 +270,16 @@ func (t *Transaction) Other(s *Posting) *Posting {
 	return nil
 }
 
+// Others returns all postings of the transaction except s.
+func (t *Transaction) Others(s *Posting) (others []*Posting) {
+	for _, ss := range t.Postings {
+		if ss != s {
+			others = append(others, ss)
+		}
+	}
+	return others
+}
+
 func (t *Transaction) HasBalanceAssertions() bool {
 	for _, p := range t.Postings {
 		if p.BalanceAsserted {
@@ -301,6 +342,12 @@ func (t *Transaction) MarshalJSON() ([]byte, error) {
 		"postings":    t.Postings,
 		"posted":      t.Posted.Format(DateFormat),
 	}
+	if !t.Time.IsZero() {
+		value["time"] = t.Time.Format(TimeFormat)
+	}
+	if !t.Effective.IsZero() {
+		value["effective"] = t.Effective.Format(DateFormat)
+	}
 	if len(t.Notes) > 0 {
 		value["notes"] = t.Notes
 	}
@@ -122,6 +122,71 @@ func Test_ParseTransactionBalance(t *testing.T) {
 	assert.Equal(t, tr.Description, "payee1")
 	assert.Equal(t, len(tr.Postings), 2)
 	assert.Equal(t, fmt.Sprintf("%a", tr.Postings[1].Balance), "50.00")
+
+	r = strings.NewReader(`
+2018/10/02 payee2
+  AA 1,234.50 CAD
+  BB -1,234.50 CAD = -12,345,678.00 CAD
+`)
+	p = NewParser(r)
+	i, err = p.Next("")
+	assert.NoError(t, err)
+	tr = i.(*Transaction)
+	assert.Equal(t, len(tr.Postings), 2)
+	assert.Equal(t, tr.Postings[0].Quantity.String(), "1234.50")
+	assert.Equal(t, tr.Postings[1].Balance.String(), "-12345678.00")
+}
+
+func Test_ParseTransactionTime(t *testing.T) {
+	r := strings.NewReader(`
+2018-10-01 09:05 (123) payee1
+  AA 10.00 CAD
+  BB
+
+2018-10-01 payee2
+  AA 10.00 CAD
+  BB
+`)
+	p := NewParser(r)
+	i, err := p.Next("")
+	assert.NoError(t, err)
+	tr := i.(*Transaction)
+	assert.Equal(t, tr.Posted.Format(DateFormat), "2018/10/01")
+	assert.Equal(t, tr.Time.Format(DateFormat+" "+TimeFormat), "2018/10/01 09:05")
+	assert.Equal(t, tr.Code, "123")
+	assert.Equal(t, tr.Description, "payee1")
+
+	i, err = p.Next("")
+	assert.NoError(t, err)
+	tr2 := i.(*Transaction)
+	assert.True(t, tr2.Time.IsZero())
+	assert.Equal(t, tr2.PostedAt(), tr2.Posted)
+	transactions := TransactionsByTime{tr2, tr}
+	assert.True(t, transactions.Less(1, 0), "morning transaction should precede noon")
+
+	_, err = NewParser(strings.NewReader("2018/10/01 25:00 payee\n  AA 10.00 CAD\n  BB\n")).Next("")
+	assert.True(t, err != nil, "invalid time of day should fail")
+}
+
+func Test_ParseTransactionEffective(t *testing.T) {
+	r := strings.NewReader(`
+2018/10/01=2018-10-04 09:05 payee1
+  AA 10.00 CAD
+  BB -10.00 CAD ; cleared [=2018/10/06]
+`)
+	p := NewParser(r)
+	i, err := p.Next("")
+	assert.NoError(t, err)
+	tr := i.(*Transaction)
+	assert.Equal(t, tr.Posted.Format(DateFormat), "2018/10/01")
+	assert.Equal(t, tr.Effective.Format(DateFormat), "2018/10/04")
+	assert.Equal(t, tr.Time.Format(TimeFormat), "09:05")
+	assert.Equal(t, tr.Description, "payee1")
+	assert.Equal(t, tr.Postings[0].EffectiveDate().Format(DateFormat), "2018/10/04")
+	assert.Equal(t, tr.Postings[1].EffectiveDate().Format(DateFormat), "2018/10/06")
+	assert.Equal(t, tr.Postings[1].Date(false), tr.Posted)
+	assert.Equal(t, tr.Postings[1].Time(false), tr.Time)
+	assert.Equal(t, tr.Postings[1].Time(true), tr.Postings[1].Effective)
 }
 
 func Test_TransactionsByTimeDay(t *testing.T) {